@@ -0,0 +1,207 @@
+package codec
+
+import (
+	"errors"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+	ErrEmptyBuilder = errors.New("no field has been written")
+)
+
+// MessageBuilder 用于在没有.proto文件的情况下构造proto二进制流数据
+//
+// 每个DecodeXXX方法和PackedRepeated/UnpackedRepeated解码器都有对应的构造方法，
+// 所有方法均返回MessageBuilder自身，以便链式调用：
+//
+//	NewBuilder().Int32(1, v).String(12, s).Message(14, sub).PackedSint64(6, xs)
+type MessageBuilder struct {
+	msg ProtoMessage
+	// err 构造过程中遇到的第一个错误，由Marshal/Err返回
+	err error
+}
+
+// NewBuilder 创建一个空的MessageBuilder
+func NewBuilder() *MessageBuilder {
+	return &MessageBuilder{
+		msg: ProtoMessage{
+			Values:   make([]ProtoValue, 0, 16),
+			sortType: NotSort,
+		},
+	}
+}
+
+// Build 返回当前已构造的ProtoMessage
+func (b *MessageBuilder) Build() ProtoMessage {
+	return b.msg
+}
+
+// Err 返回构造过程中遇到的第一个错误
+func (b *MessageBuilder) Err() error {
+	return b.err
+}
+
+// Marshal 将已构造的数据编码为proto二进制流数据
+func (b *MessageBuilder) Marshal() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.msg.Marshal()
+}
+
+// Value 返回最后写入的字段，用于ProtoMessage.SetPath，例如NewBuilder().String(3, s).Value()
+func (b *MessageBuilder) Value() (ProtoValue, error) {
+	if b.err != nil {
+		return ProtoValue{}, b.err
+	}
+	if len(b.msg.Values) == 0 {
+		return ProtoValue{}, ErrEmptyBuilder
+	}
+	return b.msg.Values[len(b.msg.Values)-1], nil
+}
+
+func (b *MessageBuilder) add(tag protowire.Number, typ protowire.Type, val interface{}) *MessageBuilder {
+	b.msg.Values = append(b.msg.Values, ProtoValue{_type: typ, val: val, tag: tag})
+	return b
+}
+
+func (b *MessageBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Int32 写入int32字段
+func (b *MessageBuilder) Int32(tag protowire.Number, v int32) *MessageBuilder {
+	return b.add(tag, protowire.VarintType, uint64(v))
+}
+
+// Int64 写入int64字段
+func (b *MessageBuilder) Int64(tag protowire.Number, v int64) *MessageBuilder {
+	return b.add(tag, protowire.VarintType, uint64(v))
+}
+
+// Uint32 写入uint32字段
+func (b *MessageBuilder) Uint32(tag protowire.Number, v uint32) *MessageBuilder {
+	return b.add(tag, protowire.VarintType, uint64(v))
+}
+
+// Uint64 写入uint64字段
+func (b *MessageBuilder) Uint64(tag protowire.Number, v uint64) *MessageBuilder {
+	return b.add(tag, protowire.VarintType, v)
+}
+
+// Sint32 写入sint32字段（ZigZag编码）
+func (b *MessageBuilder) Sint32(tag protowire.Number, v int32) *MessageBuilder {
+	return b.add(tag, protowire.VarintType, protowire.EncodeZigZag(int64(v)))
+}
+
+// Sint64 写入sint64字段（ZigZag编码）
+func (b *MessageBuilder) Sint64(tag protowire.Number, v int64) *MessageBuilder {
+	return b.add(tag, protowire.VarintType, protowire.EncodeZigZag(v))
+}
+
+// Bool 写入bool字段
+func (b *MessageBuilder) Bool(tag protowire.Number, v bool) *MessageBuilder {
+	return b.add(tag, protowire.VarintType, protowire.EncodeBool(v))
+}
+
+// Enum 写入enum字段（enum底层是int32类型）
+func (b *MessageBuilder) Enum(tag protowire.Number, v int32) *MessageBuilder {
+	return b.Int32(tag, v)
+}
+
+// Fixed64 写入fixed64字段
+func (b *MessageBuilder) Fixed64(tag protowire.Number, v uint64) *MessageBuilder {
+	return b.add(tag, protowire.Fixed64Type, v)
+}
+
+// Sfixed64 写入sfixed64字段
+func (b *MessageBuilder) Sfixed64(tag protowire.Number, v int64) *MessageBuilder {
+	return b.add(tag, protowire.Fixed64Type, uint64(v))
+}
+
+// Double 写入double字段
+func (b *MessageBuilder) Double(tag protowire.Number, v float64) *MessageBuilder {
+	return b.add(tag, protowire.Fixed64Type, math.Float64bits(v))
+}
+
+// String 写入string字段
+func (b *MessageBuilder) String(tag protowire.Number, v string) *MessageBuilder {
+	return b.add(tag, protowire.BytesType, []byte(v))
+}
+
+// Bytes 写入bytes字段
+func (b *MessageBuilder) Bytes(tag protowire.Number, v []byte) *MessageBuilder {
+	if v == nil {
+		v = []byte{}
+	}
+	return b.add(tag, protowire.BytesType, v)
+}
+
+// Message 写入嵌套message字段，sub为nil时写入空message
+func (b *MessageBuilder) Message(tag protowire.Number, sub *MessageBuilder) *MessageBuilder {
+	if sub == nil {
+		return b.add(tag, protowire.BytesType, []byte{})
+	}
+	payload, err := sub.Marshal()
+	if err != nil {
+		b.setErr(err)
+		return b
+	}
+	return b.add(tag, protowire.BytesType, payload)
+}
+
+// EmbeddedMsg 写入嵌套message字段，与DecodeEmbeddedMsg对应，用于转发解码得到的ProtoMessage
+func (b *MessageBuilder) EmbeddedMsg(tag protowire.Number, m ProtoMessage) *MessageBuilder {
+	payload, err := m.Marshal()
+	if err != nil {
+		b.setErr(err)
+		return b
+	}
+	return b.add(tag, protowire.BytesType, payload)
+}
+
+// Group 写入proto2 group字段，sub为nil时写入空group
+func (b *MessageBuilder) Group(tag protowire.Number, sub *MessageBuilder) *MessageBuilder {
+	if sub == nil {
+		return b.add(tag, protowire.StartGroupType, ProtoMessage{})
+	}
+	if sub.err != nil {
+		b.setErr(sub.err)
+		return b
+	}
+	return b.add(tag, protowire.StartGroupType, sub.msg)
+}
+
+// Fixed32 写入fixed32字段
+func (b *MessageBuilder) Fixed32(tag protowire.Number, v uint32) *MessageBuilder {
+	return b.add(tag, protowire.Fixed32Type, v)
+}
+
+// Sfixed32 写入sfixed32字段
+func (b *MessageBuilder) Sfixed32(tag protowire.Number, v int32) *MessageBuilder {
+	return b.add(tag, protowire.Fixed32Type, uint32(v))
+}
+
+// Float 写入float字段
+func (b *MessageBuilder) Float(tag protowire.Number, v float32) *MessageBuilder {
+	return b.add(tag, protowire.Fixed32Type, math.Float32bits(v))
+}
+
+// Map 写入map字段，m必须为golang map类型，每个键值对按照keyEnc和valEnc编码为一个map entry
+//
+// 键值对按照key排序后写入，保证输出结果稳定
+func (b *MessageBuilder) Map(tag protowire.Number, m interface{}, keyEnc keyEncoder, valEnc valueEncoder) *MessageBuilder {
+	entries, err := encodeMapEntries(m, keyEnc, valEnc)
+	if err != nil {
+		b.setErr(err)
+		return b
+	}
+	for _, entry := range entries {
+		b.add(tag, protowire.BytesType, entry)
+	}
+	return b
+}
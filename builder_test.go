@@ -0,0 +1,169 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/KarKLi/protobuf-golang-codec/internal/proto3_test"
+	"google.golang.org/protobuf/proto"
+)
+
+func buildEmbeeded(m *proto3_test.Embeeded) *MessageBuilder {
+	return NewBuilder().Int32(1, m.I_1).Fixed64(2, m.F_2).String(3, m.S_3).Fixed32(4, m.F_4)
+}
+
+func TestBuildNonRepeatedData(t *testing.T) {
+	bin, err := NewBuilder().
+		Int32(1, testMsg.I_1).
+		Int64(2, testMsg.I_2).
+		Uint32(3, testMsg.U_3).
+		Uint64(4, testMsg.U_4).
+		Sint32(5, testMsg.S_5).
+		Sint64(6, testMsg.S_6).
+		Bool(7, testMsg.B_7).
+		Enum(8, int32(testMsg.E_8)).
+		Fixed64(9, testMsg.F_9).
+		Sfixed64(10, testMsg.S_10).
+		Double(11, testMsg.D_11).
+		String(12, testMsg.S_12).
+		Bytes(13, testMsg.B_13).
+		Message(14, buildEmbeeded(testMsg.M_14)).
+		Fixed32(15, testMsg.F_15).
+		Sfixed32(16, testMsg.S_16).
+		Float(17, testMsg.F_17).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	realMsg := &proto3_test.Msg{}
+	if err := proto.Unmarshal(bin, realMsg); err != nil {
+		t.Fatalf("can not unmarshal build result, err: %+v", err)
+	}
+	if !proto.Equal(realMsg, testMsg) {
+		t.Fatalf("build result %v != real val %v", realMsg, testMsg)
+	}
+}
+
+func TestBuildPackedRepeatedData(t *testing.T) {
+	enums := make([]int32, 0, len(testPackedRepeatedMsg.E_8))
+	for _, e := range testPackedRepeatedMsg.E_8 {
+		enums = append(enums, int32(e))
+	}
+	bin, err := NewBuilder().
+		PackedInt32(1, testPackedRepeatedMsg.I_1).
+		PackedInt64(2, testPackedRepeatedMsg.I_2).
+		PackedUint32(3, testPackedRepeatedMsg.U_3).
+		PackedUint64(4, testPackedRepeatedMsg.U_4).
+		PackedSint32(5, testPackedRepeatedMsg.S_5).
+		PackedSint64(6, testPackedRepeatedMsg.S_6).
+		PackedBool(7, testPackedRepeatedMsg.B_7).
+		PackedEnum(8, enums).
+		PackedFixed64(9, testPackedRepeatedMsg.F_9).
+		PackedSfixed64(10, testPackedRepeatedMsg.S_10).
+		PackedDouble(11, testPackedRepeatedMsg.D_11).
+		PackedFixed32(12, testPackedRepeatedMsg.F_12).
+		PackedSfixed32(13, testPackedRepeatedMsg.S_13).
+		PackedFloat(14, testPackedRepeatedMsg.F_14).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	expectBin, err := proto.Marshal(testPackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	// 字段顺序与proto.Marshal一致，结果应逐字节相同
+	if string(bin) != string(expectBin) {
+		t.Fatalf("build result %v != marshal result %v", bin, expectBin)
+	}
+}
+
+func TestBuildUnpackedRepeatedData(t *testing.T) {
+	enums := make([]int32, 0, len(testUnpackedRepeatedMsg.E_8))
+	for _, e := range testUnpackedRepeatedMsg.E_8 {
+		enums = append(enums, int32(e))
+	}
+	m17 := make([]*MessageBuilder, 0, len(testUnpackedRepeatedMsg.M_17))
+	for _, m := range testUnpackedRepeatedMsg.M_17 {
+		m17 = append(m17, buildEmbeeded(m))
+	}
+	m20 := make(map[string]*MessageBuilder, len(testUnpackedRepeatedMsg.M_20))
+	for k, m := range testUnpackedRepeatedMsg.M_20 {
+		m20[k] = buildEmbeeded(m)
+	}
+	bin, err := NewBuilder().
+		UnpackedInt32(1, testUnpackedRepeatedMsg.I_1).
+		UnpackedInt64(2, testUnpackedRepeatedMsg.I_2).
+		UnpackedUint32(3, testUnpackedRepeatedMsg.U_3).
+		UnpackedUint64(4, testUnpackedRepeatedMsg.U_4).
+		UnpackedSint32(5, testUnpackedRepeatedMsg.S_5).
+		UnpackedSint64(6, testUnpackedRepeatedMsg.S_6).
+		UnpackedBool(7, testUnpackedRepeatedMsg.B_7).
+		UnpackedEnum(8, enums).
+		UnpackedFixed64(9, testUnpackedRepeatedMsg.F_9).
+		UnpackedSfixed64(10, testUnpackedRepeatedMsg.S_10).
+		UnpackedDouble(11, testUnpackedRepeatedMsg.D_11).
+		UnpackedFixed32(12, testUnpackedRepeatedMsg.F_12).
+		UnpackedSfixed32(13, testUnpackedRepeatedMsg.S_13).
+		UnpackedFloat(14, testUnpackedRepeatedMsg.F_14).
+		UnpackedString(15, testUnpackedRepeatedMsg.S_15).
+		UnpackedBytes(16, testUnpackedRepeatedMsg.B_16).
+		UnpackedMessage(17, m17).
+		Map(18, testUnpackedRepeatedMsg.M_18, Int32KeyEncoder, StringValueEncoder).
+		Map(19, testUnpackedRepeatedMsg.M_19, StringKeyEncoder, Int32ValueEncoder).
+		Map(20, m20, StringKeyEncoder, MessageValueEncoder).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	realMsg := &proto3_test.RepeatedMsgWithUnpacked{}
+	if err := proto.Unmarshal(bin, realMsg); err != nil {
+		t.Fatalf("can not unmarshal build result, err: %+v", err)
+	}
+	if !proto.Equal(realMsg, testUnpackedRepeatedMsg) {
+		t.Fatalf("build result %v != real val %v", realMsg, testUnpackedRepeatedMsg)
+	}
+}
+
+func TestBuildBytesKeyMap(t *testing.T) {
+	for _, c := range []struct {
+		m      interface{}
+		expect []string
+	}{
+		{map[string]int32{"a": 3, "\x01": 2, "\x00\xff": 1}, []string{"\x00\xff", "\x01", "a"}},
+		{map[[2]byte]int32{{'a'}: 3, {0x01}: 2, {0x00, 0xff}: 1}, []string{"\x00\xff", "\x01\x00", "a\x00"}},
+	} {
+		bin, err := NewBuilder().Map(1, c.m, BytesKeyEncoder, Int32ValueEncoder).Marshal()
+		if err != nil {
+			t.Fatalf("can not build test proto message, err: %+v", err)
+		}
+		msg, err := Decode(bin, NotSort)
+		if err != nil {
+			t.Fatalf("decode test proto message failed, err: %+v", err)
+		}
+		// entry按照key的字节序排列
+		for i, v := range msg.Values {
+			payload, _ := v.DecodeBytes()
+			elem, err := decodeMapEntry(payload, StringKeyDecoder, Int32ValueDecoder)
+			if err != nil {
+				t.Fatalf("decode map entry %d failed, err: %+v", i, err)
+			}
+			if elem.Key.val != c.expect[i] || elem.Value.val != int32(i+1) {
+				t.Fatalf("map entry %d %q=%v != expected %q=%d", i, elem.Key.val, elem.Value.val, c.expect[i], i+1)
+			}
+		}
+	}
+	if _, err := NewBuilder().Map(1, map[[2]int8]int32{{1}: 1}, BytesKeyEncoder, Int32ValueEncoder).Marshal(); err != ErrAssertTypeFailed {
+		t.Fatalf("expect err %v, got %v", ErrAssertTypeFailed, err)
+	}
+}
+
+func TestBuildMapTypeMismatch(t *testing.T) {
+	_, err := NewBuilder().Map(18, map[int64]string{1: "a"}, Int32KeyEncoder, StringValueEncoder).Marshal()
+	if err != ErrAssertTypeFailed {
+		t.Fatalf("expect err %v, got %v", ErrAssertTypeFailed, err)
+	}
+	_, err = NewBuilder().Map(18, []int32{1}, Int32KeyEncoder, StringValueEncoder).Marshal()
+	if err != ErrNotMapType {
+		t.Fatalf("expect err %v, got %v", ErrNotMapType, err)
+	}
+}
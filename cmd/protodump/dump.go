@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	codec "github.com/KarKLi/protobuf-golang-codec"
+	"github.com/KarKLi/protobuf-golang-codec/internal/wirefmt"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// dumper 以树的形式输出ProtoMessage中每个字段的tag、wire type、偏移量以及可能的解释
+type dumper struct {
+	w        io.Writer
+	sortType codec.MessageSortType
+	maxDepth int
+	err      error
+}
+
+func (d *dumper) printf(format string, args ...interface{}) {
+	if d.err != nil {
+		return
+	}
+	_, d.err = fmt.Fprintf(d.w, format, args...)
+}
+
+// decode 解析b并记录字段的偏移量
+func (d *dumper) decode(b []byte) (codec.ProtoMessage, error) {
+	return codec.DecodeWithOptions(b, codec.DecodeOptions{SortType: d.sortType, Spans: true})
+}
+
+// dump 输出msg中的所有字段，base为msg的字段偏移量相对于最外层数据的基准，md为nil时猜测字段类型
+func (d *dumper) dump(msg codec.ProtoMessage, md protoreflect.MessageDescriptor, base, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, v := range msg.Values {
+		var fd protoreflect.FieldDescriptor
+		if md != nil {
+			fd = md.Fields().ByNumber(v.Tag())
+		}
+		offset := base + v.Offset()
+		name := ""
+		if fd != nil {
+			name = " " + string(fd.Name())
+		}
+		switch v.Type() {
+		case protowire.VarintType, protowire.Fixed32Type, protowire.Fixed64Type:
+			d.printf("%s%d%s [%s] @%#x: %s\n", indent, v.Tag(), name, wirefmt.WireTypeName(v.Type()), offset, describeScalar(v, fd))
+		case protowire.StartGroupType:
+			group, _ := v.DecodeGroup()
+			d.printf("%s%d%s [group] @%#x:\n", indent, v.Tag(), name, offset)
+			if d.maxDepth > 0 && depth+1 >= d.maxDepth {
+				d.printf("%s  ...\n", indent)
+				continue
+			}
+			var sub protoreflect.MessageDescriptor
+			if fd != nil {
+				sub = fd.Message()
+			}
+			// group内字段的偏移量已经相对于最外层数据
+			d.dump(group, sub, base, depth+1)
+		case protowire.BytesType:
+			payload, _ := v.DecodeBytes()
+			payloadOffset := base + v.Span().PayloadOffset()
+			d.dumpBytes(v, payload, fd, indent+fmt.Sprintf("%d%s [len=%d] @%#x", v.Tag(), name, len(payload), offset), payloadOffset, depth)
+		}
+	}
+}
+
+func (d *dumper) dumpBytes(v codec.ProtoValue, payload []byte, fd protoreflect.FieldDescriptor, prefix string, payloadOffset, depth int) {
+	expand := d.maxDepth <= 0 || depth+1 < d.maxDepth
+	if fd != nil {
+		switch {
+		case fd.Message() != nil:
+			if !expand {
+				d.printf("%s: message ...\n", prefix)
+				return
+			}
+			sub, err := d.decode(payload)
+			if err != nil {
+				d.printf("%s: invalid message %s\n", prefix, strconv.Quote(string(payload)))
+				return
+			}
+			d.printf("%s: message\n", prefix)
+			d.dump(sub, fd.Message(), payloadOffset, depth+1)
+		case fd.Kind() == protoreflect.StringKind:
+			d.printf("%s: %s\n", prefix, strconv.Quote(string(payload)))
+		case fd.Kind() == protoreflect.BytesKind:
+			d.printf("%s: bytes %x\n", prefix, payload)
+		default:
+			d.printf("%s: packed %s %s\n", prefix, fd.Kind(), describePacked(payload, fd.Kind()))
+		}
+		return
+	}
+	if len(payload) > 0 && wirefmt.IsPrintable(payload) {
+		d.printf("%s: %s\n", prefix, strconv.Quote(string(payload)))
+		return
+	}
+	if sub, err := d.decode(payload); err == nil && len(payload) > 0 {
+		if !expand {
+			d.printf("%s: message ...\n", prefix)
+			return
+		}
+		d.printf("%s: message\n", prefix)
+		d.dump(sub, nil, payloadOffset, depth+1)
+		return
+	}
+	if packed := describePacked(payload, protoreflect.Int64Kind); len(payload) > 0 && packed != "" {
+		d.printf("%s: bytes %x (packed varint %s)\n", prefix, payload, packed)
+		return
+	}
+	d.printf("%s: bytes %x\n", prefix, payload)
+}
+
+// describeScalar 根据字段描述输出数据，没有描述时输出所有可能的解释
+func describeScalar(v codec.ProtoValue, fd protoreflect.FieldDescriptor) string {
+	if fd != nil {
+		if val, err := v.DecodeKind(fd.Kind()); err == nil {
+			if fd.Kind() == protoreflect.EnumKind {
+				if ev := fd.Enum().Values().ByNumber(protoreflect.EnumNumber(val.(int32))); ev != nil {
+					return fmt.Sprintf("%s (%d)", ev.Name(), val)
+				}
+			}
+			return fmt.Sprintf("%v (%s)", val, fd.Kind())
+		}
+	}
+	switch v.Type() {
+	case protowire.VarintType:
+		val, _ := v.DecodeUint64()
+		s := fmt.Sprintf("%d (int64: %d, sint64: %d", val, int64(val), protowire.DecodeZigZag(val))
+		if val <= 1 {
+			s += fmt.Sprintf(", bool: %v", val == 1)
+		}
+		return s + ")"
+	case protowire.Fixed32Type:
+		val, _ := v.DecodeFixed32()
+		return fmt.Sprintf("0x%08x (fixed32: %d, sfixed32: %d, float: %g)", val, val, int32(val), math.Float32frombits(val))
+	case protowire.Fixed64Type:
+		val, _ := v.DecodeFixed64()
+		return fmt.Sprintf("0x%016x (fixed64: %d, sfixed64: %d, double: %g)", val, val, int64(val), math.Float64frombits(val))
+	}
+	return ""
+}
+
+// describePacked 按照kind解析packed数据，解析失败时返回空字符串
+func describePacked(payload []byte, kind protoreflect.Kind) string {
+	var elems []string
+	for len(payload) > 0 {
+		var s string
+		var n int
+		switch kind {
+		case protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind, protoreflect.FloatKind:
+			var val uint32
+			val, n = protowire.ConsumeFixed32(payload)
+			switch kind {
+			case protoreflect.Sfixed32Kind:
+				s = fmt.Sprint(int32(val))
+			case protoreflect.FloatKind:
+				s = fmt.Sprint(math.Float32frombits(val))
+			default:
+				s = fmt.Sprint(val)
+			}
+		case protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind, protoreflect.DoubleKind:
+			var val uint64
+			val, n = protowire.ConsumeFixed64(payload)
+			switch kind {
+			case protoreflect.Sfixed64Kind:
+				s = fmt.Sprint(int64(val))
+			case protoreflect.DoubleKind:
+				s = fmt.Sprint(math.Float64frombits(val))
+			default:
+				s = fmt.Sprint(val)
+			}
+		default:
+			var val uint64
+			val, n = protowire.ConsumeVarint(payload)
+			switch kind {
+			case protoreflect.Sint32Kind, protoreflect.Sint64Kind:
+				s = fmt.Sprint(protowire.DecodeZigZag(val))
+			case protoreflect.Uint32Kind, protoreflect.Uint64Kind:
+				s = fmt.Sprint(val)
+			case protoreflect.BoolKind:
+				s = fmt.Sprint(val != 0)
+			case protoreflect.Int32Kind, protoreflect.EnumKind:
+				s = fmt.Sprint(int32(val))
+			default:
+				s = fmt.Sprint(int64(val))
+			}
+		}
+		if n < 0 {
+			return ""
+		}
+		elems = append(elems, s)
+		payload = payload[n:]
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
@@ -0,0 +1,167 @@
+// protodump 在没有.proto文件的情况下查看proto二进制流数据
+//
+// 用法：
+//
+//	protodump [flags] [file]
+//	protodump query [flags] QUERY [file]
+//
+// 未指定file时从标准输入读取数据。query子命令的语法见query包。
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	codec "github.com/KarKLi/protobuf-golang-codec"
+	"github.com/KarKLi/protobuf-golang-codec/query"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "protodump: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) > 0 && args[0] == "query" {
+		return runQuery(args[1:], stdin, stdout)
+	}
+	fs := flag.NewFlagSet("protodump", flag.ContinueOnError)
+	format := fs.String("format", "raw", "input format: raw, hex or base64")
+	sortOrder := fs.String("sort", "none", "field order: none (wire order), asc or desc")
+	maxDepth := fs.Int("depth", 0, "max nesting depth to expand, 0 means unlimited")
+	descriptorSet := fs.String("descriptor_set", "", "FileDescriptorSet file for named output, comma separated for multiple files")
+	msgType := fs.String("type", "", "fully-qualified message name used with -descriptor_set")
+	hexDump := fs.Bool("hexdump", false, "print an annotated hexdump mapping every byte to its field path")
+	color := fs.Bool("color", false, "colorize -hexdump output with ANSI escape codes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	sortType, err := parseSortType(*sortOrder)
+	if err != nil {
+		return err
+	}
+	var md protoreflect.MessageDescriptor
+	if *descriptorSet != "" {
+		if *msgType == "" {
+			return fmt.Errorf("-type is required when -descriptor_set is set")
+		}
+		r := codec.NewRegistry()
+		if err := r.LoadDescriptorSetFile(strings.Split(*descriptorSet, ",")...); err != nil {
+			return err
+		}
+		if md, err = r.FindMessage(*msgType); err != nil {
+			return err
+		}
+	}
+	data, err := readInput(fs.Arg(0), stdin, *format)
+	if err != nil {
+		return err
+	}
+	if *hexDump {
+		// 数据损坏时同样输出能够解析的部分
+		return codec.HexDump(stdout, data, codec.HexDumpOptions{Color: *color, MaxDepth: *maxDepth})
+	}
+	d := &dumper{w: stdout, sortType: sortType, maxDepth: *maxDepth}
+	msg, err := d.decode(data)
+	if err != nil {
+		return err
+	}
+	d.dump(msg, md, 0, 0)
+	return d.err
+}
+
+// runQuery 执行query子命令，每个结果按照query.Format输出
+func runQuery(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("protodump query", flag.ContinueOnError)
+	format := fs.String("format", "raw", "input format: raw, hex or base64")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return fmt.Errorf("usage: protodump query [flags] QUERY [file]")
+	}
+	q, err := query.Parse(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	data, err := readInput(fs.Arg(1), stdin, *format)
+	if err != nil {
+		return err
+	}
+	msg, err := codec.Decode(data, codec.NotSort)
+	if err != nil {
+		return err
+	}
+	out, err := q.Run(msg)
+	if err != nil {
+		return err
+	}
+	for _, v := range out {
+		if _, err := io.WriteString(stdout, query.Format(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseSortType(s string) (codec.MessageSortType, error) {
+	switch s {
+	case "none":
+		return codec.NotSort, nil
+	case "asc":
+		return codec.Asc, nil
+	case "desc":
+		return codec.Desc, nil
+	}
+	return codec.NotSort, fmt.Errorf("unknown sort order %q", s)
+}
+
+// readInput 读取文件或标准输入，并按照format解码为二进制数据
+func readInput(path string, stdin io.Reader, format string) ([]byte, error) {
+	var data []byte
+	var err error
+	if path == "" || path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "raw":
+		return data, nil
+	case "hex":
+		return hex.DecodeString(stripSpace(string(data)))
+	case "base64":
+		s := stripSpace(string(data))
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if b, err := enc.DecodeString(s); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid base64 input")
+	}
+	return nil, fmt.Errorf("unknown input format %q", format)
+}
+
+func stripSpace(s string) string {
+	var buf bytes.Buffer
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
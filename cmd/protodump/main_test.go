@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	codec "github.com/KarKLi/protobuf-golang-codec"
+	"github.com/KarKLi/protobuf-golang-codec/internal/proto3_test"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestRunHexInput(t *testing.T) {
+	bin, err := codec.NewBuilder().
+		Uint64(1, 150).
+		String(2, "testing").
+		Message(3, codec.NewBuilder().Int32(1, -1).Fixed32(2, 0x3f800000)).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	var out bytes.Buffer
+	if err := run([]string{"-format", "hex", "-sort", "desc"}, strings.NewReader(hex.EncodeToString(bin)), &out); err != nil {
+		t.Fatalf("run protodump failed, err: %+v", err)
+	}
+	expect := `3 [len=16] @0xc: message
+  2 [i32] @0x19: 0x3f800000 (fixed32: 1065353216, sfixed32: 1065353216, float: 1)
+  1 [varint] @0xe: 18446744073709551615 (int64: -1, sint64: -9223372036854775808)
+2 [len=7] @0x3: "testing"
+1 [varint] @0x0: 150 (int64: 150, sint64: 75)
+`
+	if out.String() != expect {
+		t.Fatalf("dump result:\n%s\n!= expected:\n%s", out.String(), expect)
+	}
+
+	out.Reset()
+	if err := run([]string{"-format", "hex", "-depth", "1"}, strings.NewReader(hex.EncodeToString(bin)), &out); err != nil {
+		t.Fatalf("run protodump failed, err: %+v", err)
+	}
+	if !strings.Contains(out.String(), "3 [len=16] @0xc: message ...\n") {
+		t.Fatalf("dump result:\n%s\nnot limited by depth", out.String())
+	}
+}
+
+func TestRunWithDescriptorSet(t *testing.T) {
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(proto3_test.File_proto3_test_proto),
+		},
+	}
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatalf("can not marshal descriptor set, err: %+v", err)
+	}
+	path := filepath.Join(t.TempDir(), "proto3_test.pb")
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatalf("can not write descriptor set, err: %+v", err)
+	}
+	bin, err := proto.Marshal(&proto3_test.RepeatedMsgWithPacked{
+		S_5: []int32{-1, 2},
+		E_8: []proto3_test.TestEnum{proto3_test.TestEnum_TWO},
+	})
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	var out bytes.Buffer
+	if err := run([]string{"-descriptor_set", path, "-type", "RepeatedMsgWithPacked"}, bytes.NewReader(bin), &out); err != nil {
+		t.Fatalf("run protodump failed, err: %+v", err)
+	}
+	expect := `5 s_5 [len=2] @0x0: packed sint32 [-1, 2]
+8 e_8 [len=1] @0x4: packed enum [2]
+`
+	if out.String() != expect {
+		t.Fatalf("dump result:\n%s\n!= expected:\n%s", out.String(), expect)
+	}
+}
+
+func TestRunHexDump(t *testing.T) {
+	bin, err := codec.NewBuilder().Uint64(1, 150).Message(14, codec.NewBuilder().Int32(3, 1)).Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	// 截断的数据同样输出能够解析的部分
+	bin = append(bin, 0x12)
+	var out bytes.Buffer
+	if err := run([]string{"-hexdump"}, bytes.NewReader(bin), &out); err != nil {
+		t.Fatalf("run protodump failed, err: %+v", err)
+	}
+	expect := `00000000  08                                               1     tag 1 varint
+00000001  96 01                                            1     varint 150
+00000003  72                                               14    tag 14 len
+00000004  02                                               14    length 2
+00000005  18                                               14.3  tag 3 varint
+00000006  01                                               14.3  varint 1
+00000007  12                                               ???   invalid: unexpected EOF
+`
+	if out.String() != expect {
+		t.Fatalf("dump result:\n%s\n!= expected:\n%s", out.String(), expect)
+	}
+}
+
+func TestRunQuery(t *testing.T) {
+	bin, err := codec.NewBuilder().
+		Message(17, codec.NewBuilder().Sint32(1, 3).String(3, "a")).
+		Message(17, codec.NewBuilder().Sint32(1, 7).String(3, "b")).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	var out bytes.Buffer
+	args := []string{"query", "-format", "hex", "17[] | select(.1 as sint32 > 5) | .3 as string, ."}
+	if err := run(args, strings.NewReader(hex.EncodeToString(bin)), &out); err != nil {
+		t.Fatalf("run protodump query failed, err: %+v", err)
+	}
+	expect := `"b"
+17 {
+  1: 14
+  3: "b"
+}
+`
+	if out.String() != expect {
+		t.Fatalf("query result:\n%s\n!= expected:\n%s", out.String(), expect)
+	}
+	if err := run([]string{"query", "17[ "}, strings.NewReader(""), &out); err == nil {
+		t.Fatalf("run protodump query with invalid query should fail")
+	}
+}
@@ -13,10 +13,13 @@ var (
 	ErrAssertTypeFailed    = errors.New("assert value type failed")
 	ErrDataNotRepeatedData = errors.New("expected repeated data, got singular data")
 	ErrDataNotSingularData = errors.New("expected singular data, got repeated data")
+	ErrUnexpectedEndGroup  = errors.New("unexpected end group without matched start group")
 )
 
 type ProtoValue struct {
 	_type protowire.Type
+	// tag 该字段的实际tag，与_type相邻以减小ProtoValue的大小
+	tag protowire.Number
 	/*
 		由type的类型决定，可能为：
 
@@ -25,33 +28,123 @@ type ProtoValue struct {
 		uint64（VarintType/Fixed64Type）
 
 		[]byte（BytesType）
+
+		ProtoMessage（StartGroupType，proto2 group）
 	*/
 	val interface{}
-	// tag 该字段的实际tag
-	tag protowire.Number
+	// ext 该字段的位置和延迟解析缓存，只在开启DecodeOptions.Spans或DecodeOptions.Lazy时分配
+	ext *valueExt
+}
+
+// valueExt ProtoValue的附加信息，复制ProtoValue时共享
+type valueExt struct {
+	// spanned 是否记录了span和path（DecodeOptions.Spans）
+	spanned bool
+	// span 该字段在Decode输入数据中占用的字节范围
+	span FieldSpan
+	// path 该字段所在message的tag路径，多个字段共享同一个slice，不可修改
+	path []protowire.Number
+	// lazy 延迟解析模式下BytesType字段解析结果的缓存
+	lazy *lazyMessage
+}
+
+// FieldSpan 字段在Decode输入数据中占用的字节范围
+//
+// group内的字段以及DecodeEmbeddedMsg得到的字段同样相对于最外层的输入数据
+type FieldSpan struct {
+	// Offset tag的起始偏移量
+	Offset int
+	// TagLen tag占用的字节数
+	TagLen int
+	// PrefixLen BytesType长度前缀占用的字节数，其他类型为0
+	PrefixLen int
+	// PayloadLen 数据占用的字节数，group为不包括结束tag的全部内容
+	PayloadLen int
+	// EndTagLen group结束tag占用的字节数，其他类型为0
+	EndTagLen int
+}
+
+// PayloadOffset 返回数据的起始偏移量
+func (s FieldSpan) PayloadOffset() int {
+	return s.Offset + s.TagLen + s.PrefixLen
+}
+
+// End 返回该字段结束位置（不包含）的偏移量
+func (s FieldSpan) End() int {
+	return s.PayloadOffset() + s.PayloadLen + s.EndTagLen
+}
+
+// Tag 返回该字段的tag
+func (p ProtoValue) Tag() protowire.Number {
+	return p.tag
+}
+
+// Type 返回该字段的wire type，proto2 group的wire type为protowire.StartGroupType
+func (p ProtoValue) Type() protowire.Type {
+	return p._type
+}
+
+// Offset 返回该字段tag在Decode输入数据中的起始偏移量，解析时未开启DecodeOptions.Spans时返回0
+func (p ProtoValue) Offset() int {
+	return p.Span().Offset
+}
+
+// Span 返回该字段在Decode输入数据中占用的字节范围，解析时未开启DecodeOptions.Spans时返回空的FieldSpan
+func (p ProtoValue) Span() FieldSpan {
+	if p.ext == nil || !p.ext.spanned {
+		return FieldSpan{}
+	}
+	return p.ext.span
+}
+
+// spanPath 返回该字段的数据在Decode输入数据中的起始偏移量以及该字段的tag路径，未记录位置时返回0和nil
+func (p ProtoValue) spanPath() (int, []protowire.Number) {
+	if p.ext == nil || !p.ext.spanned {
+		return 0, nil
+	}
+	return p.ext.span.PayloadOffset(), appendPath(p.ext.path, p.tag)
+}
+
+// memo 返回延迟解析的缓存，非延迟解析模式下返回nil
+func (p ProtoValue) memo() *lazyMessage {
+	if p.ext == nil {
+		return nil
+	}
+	return p.ext.lazy
+}
+
+// setMemo 替换延迟解析的缓存，不修改与其他ProtoValue共享的附加信息
+func (p *ProtoValue) setMemo(l *lazyMessage) {
+	ext := &valueExt{}
+	if p.ext != nil {
+		*ext = *p.ext
+	}
+	ext.lazy = l
+	p.ext = ext
 }
 
 type ProtoMessage struct {
 	Values   []ProtoValue
 	sortType MessageSortType
+	// index tag到Values下标的索引，由BuildIndex建立
+	index map[protowire.Number][]int
 }
 
 // GetRepeatedData 返回ProtoMessage中所有满足传入tag的底层数据索引
+//
+// 已经建立索引时返回索引中的切片，调用方不应修改
 func (p *ProtoMessage) GetRepeatedData(tag protowire.Number) ([]int, error) {
+	if p.index != nil {
+		idxs := p.index[tag]
+		return idxs[:len(idxs):len(idxs)], nil
+	}
 	idxs := make([]int, 0, len(p.Values))
 	if p.sortType != NotSort {
 		// 二分法寻找
-		idx := sort.Search(len(p.Values), func(i int) bool {
-			return int32(p.Values[i].tag) >= int32(tag)
-		})
-		if idx == -1 {
-			return nil, nil
-		}
-		var i int
-		for i = idx; i < len(p.Values); i++ {
-			if p.Values[i].tag != tag {
-				break
-			}
+		idx := p.search(tag)
+		i := idx
+		for i < len(p.Values) && p.Values[i].tag == tag {
+			i++
 		}
 		for j := idx; j < i; j++ {
 			idxs = append(idxs, j)
@@ -67,17 +160,26 @@ func (p *ProtoMessage) GetRepeatedData(tag protowire.Number) ([]int, error) {
 }
 
 func (p *ProtoMessage) GetData(tag protowire.Number) (ProtoValue, error) {
+	if p.index != nil {
+		idxs := p.index[tag]
+		switch {
+		case len(idxs) == 0:
+			return ProtoValue{}, nil
+		case len(idxs) > 1 && p.sortType != NotSort:
+			return ProtoValue{}, ErrDataNotSingularData
+		}
+		return p.Values[idxs[0]], nil
+	}
 	if p.sortType != NotSort {
 		// 二分法寻找
-		idx := sort.Search(len(p.Values), func(i int) bool {
-			return int32(p.Values[i].tag) >= int32(tag)
-		})
-		if idx == -1 {
+		idx := p.search(tag)
+		if idx >= len(p.Values) || p.Values[idx].tag != tag {
 			return ProtoValue{}, nil
 		}
 		if idx+1 < len(p.Values) && p.Values[idx+1].tag == tag {
 			return ProtoValue{}, ErrDataNotSingularData
 		}
+		return p.Values[idx], nil
 	}
 	// 退化为顺序查找
 	for i := 0; i < len(p.Values); i++ {
@@ -99,19 +201,97 @@ const (
 	Desc
 )
 
+// DecodeOptions DecodeWithOptions的选项
+type DecodeOptions struct {
+	// SortType 字段的排序方式
+	SortType MessageSortType
+	// Lazy 延迟解析模式，BytesType字段在首次调用DecodeEmbeddedMsg时才解析为嵌套message，并缓存解析结果
+	//
+	// 嵌套message同样按照延迟解析模式解析，适合只读取大message中少量深层字段的场景
+	Lazy bool
+	// Index 解析完成后建立tag索引（见ProtoMessage.BuildIndex），group和延迟解析的嵌套message同样建立索引
+	Index bool
+	// Spans 记录每个字段在输入数据中占用的字节范围（见ProtoValue.Span）和嵌套路径
+	//
+	// 记录后DecodeEmbeddedMsg得到的字段以及解析失败时返回的*DecodeError同样相对于最外层的输入数据；
+	// 不记录时不占用额外的内存，嵌套message解析失败时*DecodeError的偏移量相对于该嵌套message的数据
+	Spans bool
+}
+
 // Decode 解析proto二进制流数据
+//
+// 解析失败时返回*DecodeError，记录出错字段的偏移量、tag、wire type以及嵌套路径
 func Decode(b []byte, sortType MessageSortType) (ProtoMessage, error) {
+	return DecodeWithOptions(b, DecodeOptions{SortType: sortType})
+}
+
+// DecodeWithOptions 按照opts解析proto二进制流数据
+func DecodeWithOptions(b []byte, opts DecodeOptions) (ProtoMessage, error) {
+	m, err := decode(b, opts, 0, nil)
+	if err != nil {
+		return ProtoMessage{}, err
+	}
+	return m, nil
+}
+
+// decode 解析proto二进制流数据，base为b在最外层输入数据中的偏移量，path为b所在字段的tag路径
+//
+// 解析失败时同时返回出错前已解析的字段（不排序）
+func decode(b []byte, opts DecodeOptions, base int, path []protowire.Number) (ProtoMessage, error) {
+	m, exts, lazyCount, err := decodeFields(b, opts, base, path)
+	// 解析完成（或者解析失败）后统一设置位置，避免exts扩容后指针失效
+	for i := range exts {
+		m.Values[i].ext = &exts[i]
+	}
+	if err != nil {
+		return m, err
+	}
+	if opts.Lazy && lazyCount > 0 {
+		// 一次性分配所有BytesType字段的缓存
+		memos := make([]lazyMessage, lazyCount)
+		var lazyExts []valueExt
+		if !opts.Spans {
+			lazyExts = make([]valueExt, lazyCount)
+		}
+		for i := range m.Values {
+			if m.Values[i]._type == protowire.BytesType {
+				k := len(memos) - lazyCount
+				memos[k].index = opts.Index
+				if m.Values[i].ext == nil {
+					m.Values[i].ext = &lazyExts[k]
+				}
+				m.Values[i].ext.lazy = &memos[k]
+				lazyCount--
+			}
+		}
+	}
+	m.sort()
+	if opts.Index {
+		m.BuildIndex()
+	}
+	return m, nil
+}
+
+// decodeFields 按照出现顺序解析b中的字段，DecodeOptions.Spans为true时同时返回每个字段的位置，以及BytesType字段的数量
+func decodeFields(b []byte, opts DecodeOptions, base int, path []protowire.Number) (ProtoMessage, []valueExt, int, error) {
 	m := ProtoMessage{
 		Values:   make([]ProtoValue, 0, 16),
-		sortType: sortType,
+		sortType: opts.SortType,
 	}
+	var exts []valueExt
+	if opts.Spans {
+		exts = make([]valueExt, 0, 16)
+	}
+	lazyCount := 0
+	total := len(b)
 	for len(b) > 0 {
-		var n int
+		offset := base + total - len(b)
 		num, typ, n := protowire.ConsumeTag(b)
 		if n < 0 {
-			return ProtoMessage{}, protowire.ParseError(n)
+			return m, exts, lazyCount, &DecodeError{Offset: offset, Path: path, Err: protowire.ParseError(n)}
 		}
 		b = b[n:]
+		span := FieldSpan{Offset: offset, TagLen: n}
 		var val interface{}
 		switch typ {
 		case protowire.VarintType:
@@ -121,26 +301,73 @@ func Decode(b []byte, sortType MessageSortType) (ProtoMessage, error) {
 		case protowire.Fixed64Type:
 			val, n = protowire.ConsumeFixed64(b)
 		case protowire.BytesType:
-			val, n = protowire.ConsumeBytes(b)
+			var payload []byte
+			payload, n = protowire.ConsumeBytes(b)
+			span.PrefixLen = n - len(payload)
+			span.PayloadLen = len(payload)
+			val = payload
+			lazyCount++
+		case protowire.StartGroupType:
+			// group内容作为嵌套ProtoMessage解析，ConsumeGroup会校验结束tag与起始tag一致
+			var body []byte
+			body, n = protowire.ConsumeGroup(num, b)
+			if n < 0 {
+				// 逐个解析group内的字段，以便定位到出错的内层字段
+				_, err := decode(b, opts, span.PayloadOffset(), appendPath(path, num))
+				if err != nil && !errors.Is(err, ErrUnexpectedEndGroup) {
+					return m, exts, lazyCount, err
+				}
+				break
+			}
+			span.PayloadLen = len(body)
+			span.EndTagLen = n - len(body)
+			// group内的错误已经是*DecodeError
+			group, err := decode(body, opts, span.PayloadOffset(), appendPath(path, num))
+			if err != nil {
+				return m, exts, lazyCount, err
+			}
+			val = group
+		case protowire.EndGroupType:
+			return m, exts, lazyCount, &DecodeError{Offset: offset, Tag: num, WireType: typ, Path: path, Err: ErrUnexpectedEndGroup}
 		default:
-			return ProtoMessage{}, fmt.Errorf("not support proto data type %d", typ)
+			return m, exts, lazyCount, &DecodeError{Offset: offset, Tag: num, WireType: typ, Path: path, Err: fmt.Errorf("not support proto data type %d", typ)}
 		}
 		if n < 0 {
-			return ProtoMessage{}, protowire.ParseError(n)
+			return m, exts, lazyCount, &DecodeError{Offset: offset, Tag: num, WireType: typ, Path: path, Err: protowire.ParseError(n)}
+		}
+		if typ != protowire.BytesType && typ != protowire.StartGroupType {
+			span.PayloadLen = n
 		}
 		m.Values = append(m.Values, ProtoValue{_type: typ, val: val, tag: num})
+		if opts.Spans {
+			exts = append(exts, valueExt{spanned: true, span: span, path: path})
+		}
 		b = b[n:]
 	}
-	switch sortType {
+	return m, exts, lazyCount, nil
+}
+
+// search 在已排序的字段中二分查找tag第一次出现的位置，不存在时返回应当插入的位置
+func (p *ProtoMessage) search(tag protowire.Number) int {
+	return sort.Search(len(p.Values), func(i int) bool {
+		if p.sortType == Desc {
+			return p.Values[i].tag <= tag
+		}
+		return p.Values[i].tag >= tag
+	})
+}
+
+// sort 按照sortType对字段排序，tag相同的字段保持原始顺序，以便GetLastData和GetMergedMessage按照出现顺序处理
+func (p *ProtoMessage) sort() {
+	switch p.sortType {
 	case NotSort:
 	case Asc:
-		sort.Slice(m.Values, func(i, j int) bool {
-			return m.Values[i].tag < m.Values[j].tag
+		sort.SliceStable(p.Values, func(i, j int) bool {
+			return p.Values[i].tag < p.Values[j].tag
 		})
 	case Desc:
-		sort.Slice(m.Values, func(i, j int) bool {
-			return m.Values[i].tag > m.Values[j].tag
+		sort.SliceStable(p.Values, func(i, j int) bool {
+			return p.Values[i].tag > p.Values[j].tag
 		})
 	}
-	return m, nil
 }
@@ -272,3 +272,39 @@ func BenchmarkDecodeUnpackedRepeatedData(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkDecodeLazyEmbeddedMsg(b *testing.B) {
+	initTestData(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m, err := DecodeWithOptions(testUnpackedRepeatedBin, DecodeOptions{SortType: Asc, Lazy: true})
+		if err != nil {
+			b.Fatalf("decode RepeatedMsgWithUnpacked proto message into ProtoMessage struct failed, err: %+v", err)
+		}
+		// 只读取最后一个嵌套message中的一个字段
+		idxs, err := m.GetRepeatedData(17)
+		if err != nil {
+			b.Fatalf("can not get tag=17's data, err: %+v", err)
+		}
+		msg, err := m.Values[idxs[len(idxs)-1]].DecodeEmbeddedMsg(Asc)
+		if err != nil {
+			b.Fatalf("decode RepeatedMsgWithUnpacked 17 field failed, err: %+v", err)
+		}
+		_, _ = msg.GetData(3)
+	}
+}
+
+func BenchmarkGetDataIndexed(b *testing.B) {
+	initTestData(b)
+	m, err := DecodeWithOptions(testUnpackedRepeatedBin, DecodeOptions{Index: true})
+	if err != nil {
+		b.Fatalf("decode RepeatedMsgWithUnpacked proto message into ProtoMessage struct failed, err: %+v", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.GetRepeatedData(20); err != nil {
+			b.Fatalf("can not get tag=20's data, err: %+v", err)
+		}
+	}
+}
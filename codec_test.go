@@ -2,12 +2,16 @@ package codec
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"math"
 	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/KarKLi/protobuf-golang-codec/internal/proto3_test"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -374,6 +378,71 @@ func TestDecodePackedRepeatedData(t *testing.T) {
 	}
 }
 
+func TestDecodePackedRepeatedChunks(t *testing.T) {
+	// 其他实现可能将packed字段拆分为多段，或者混有未packed的元素
+	bin, err := NewBuilder().
+		PackedInt32(1, []int32{1, -2}).
+		Int32(1, 3).
+		PackedFixed32(12, []uint32{7}).
+		PackedInt32(1, []int32{4, 5}).
+		Fixed32(12, 8).
+		PackedFixed32(12, []uint32{9, 10}).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	expect := &proto3_test.RepeatedMsgWithPacked{}
+	if err := proto.Unmarshal(bin, expect); err != nil {
+		t.Fatalf("can not unmarshal test proto message, err: %+v", err)
+	}
+	for _, sortType := range []MessageSortType{NotSort, Asc, Desc} {
+		m, err := Decode(bin, sortType)
+		if err != nil {
+			t.Fatalf("decode test proto message failed, err: %+v", err)
+		}
+		realI1, err := m.DecodePackedRepeated(1, PackedRepeatedInt32Decoder)
+		if err != nil {
+			t.Fatalf("can not parse tag 1, err: %+v", err)
+		}
+		if !reflect.DeepEqual(realI1.([]int32), expect.I_1) {
+			t.Fatalf("parse result %v != real val %v", realI1.([]int32), expect.I_1)
+		}
+		realF12, err := m.DecodePackedRepeated(12, PackedRepeatedFixed32Decoder)
+		if err != nil {
+			t.Fatalf("can not parse tag 12, err: %+v", err)
+		}
+		if !reflect.DeepEqual(realF12.([]uint32), expect.F_12) {
+			t.Fatalf("parse result %v != real val %v", realF12.([]uint32), expect.F_12)
+		}
+		// 字段不存在时返回空数据
+		realI2, err := m.DecodePackedRepeated(2, PackedRepeatedInt64Decoder)
+		if err != nil || len(realI2.([]int64)) != 0 {
+			t.Fatalf("parse missing tag 2 returns %v, err: %+v", realI2, err)
+		}
+		// 未packed元素的wire type与decoder不一致
+		if _, err := m.DecodePackedRepeated(12, PackedRepeatedInt32Decoder); err != ErrTypeMismatch {
+			t.Fatalf("parse fixed32 elements as int32 should fail, err: %+v", err)
+		}
+		if _, err := m.DecodePackedRepeated(1, PackedRepeatedSfixed32Decoder); err != ErrTypeMismatch {
+			t.Fatalf("parse varint elements as sfixed32 should fail, err: %+v", err)
+		}
+	}
+
+	// 包装内置decoder的自定义decoder按照声明的wire type拼接未packed的元素
+	m, _ := Decode(bin, NotSort)
+	wrapped := NewPackedRepeatedDecoder(protowire.VarintType, func(p ProtoValue) (interface{}, error) {
+		return PackedRepeatedInt32Decoder.Decode(p)
+	})
+	if realI1, err := m.DecodePackedRepeated(1, wrapped); err != nil || !reflect.DeepEqual(realI1, expect.I_1) {
+		t.Fatalf("parse result %v != real val %v, err: %+v", realI1, expect.I_1, err)
+	}
+	// wire type为BytesType的自定义decoder只能解析packed数据
+	packedOnly := NewPackedRepeatedDecoder(protowire.BytesType, PackedRepeatedInt32Decoder.Decode)
+	if _, err := m.DecodePackedRepeated(1, packedOnly); err != ErrTypeMismatch {
+		t.Fatalf("parse unpacked elements with packed only decoder should fail, err: %+v", err)
+	}
+}
+
 func TestDecodeUnpackedRepeatedData(t *testing.T) {
 	testMsg := &proto3_test.RepeatedMsgWithUnpacked{
 		I_1:  []int32{0, math.MaxInt32, rand.Int31(), -rand.Int31()},
@@ -664,3 +733,312 @@ func checkEmbeededMsgEqual(t *testing.T, m1 ProtoMessage, m2 *proto3_test.Embeed
 		t.Fatalf("parse result %d != real val %d", realM14F4, m2.F_4)
 	}
 }
+
+func TestDecodeMapEntry(t *testing.T) {
+	// 其他实现编码的map entry：key或value缺省、顺序颠倒、重复出现以及包含未知字段
+	bin, err := NewBuilder().
+		Message(18, NewBuilder()).
+		Message(18, NewBuilder().String(2, "a")).
+		Message(18, NewBuilder().Int32(1, 5)).
+		Message(18, NewBuilder().String(2, "b").Int32(1, 6)).
+		Message(18, NewBuilder().Int32(1, 7).String(2, "c").Int32(1, 8).String(2, "d")).
+		Message(18, NewBuilder().Int32(1, 9).Fixed64(3, 1).Group(4, NewBuilder().Int32(1, 10)).String(2, "e")).
+		Message(18, NewBuilder().Int32(1, 11).Int32(2, 12)).
+		Message(18, NewBuilder().String(1, "x").Int32(1, 13).String(2, "f").Fixed32(2, 14)).
+		Message(19, NewBuilder().Int32(2, 3)).
+		Message(20, NewBuilder().String(1, "aa")).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	testMsg := &proto3_test.RepeatedMsgWithUnpacked{}
+	if err := proto.Unmarshal(bin, testMsg); err != nil {
+		t.Fatalf("can not unmarshal test proto message, err: %+v", err)
+	}
+	m, err := Decode(bin, Asc)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	realM18, err := m.DecodeMap(18, Int32KeyDecoder, StringValueDecoder)
+	if err != nil {
+		t.Fatalf("can not parse tag 18, err: %+v", err)
+	}
+	realM18ReflectMap, err := FillMapFromProtoMapElem(realM18)
+	if err != nil {
+		t.Fatalf("can not convert tag 18 proto map elems into reflect map, err: %+v", err)
+	}
+	realM18Map := realM18ReflectMap.Interface().(map[int32]string)
+	if !reflect.DeepEqual(realM18Map, testMsg.M_18) {
+		t.Fatalf("parse result %v != real val %v", realM18Map, testMsg.M_18)
+	}
+	realM19, err := m.DecodeMap(19, StringKeyDecoder, Int32ValueDecoder)
+	if err != nil {
+		t.Fatalf("can not parse tag 19, err: %+v", err)
+	}
+	realM19ReflectMap, err := FillMapFromProtoMapElem(realM19)
+	if err != nil {
+		t.Fatalf("can not convert tag 19 proto map elems into reflect map, err: %+v", err)
+	}
+	realM19Map := realM19ReflectMap.Interface().(map[string]int32)
+	if !reflect.DeepEqual(realM19Map, testMsg.M_19) {
+		t.Fatalf("parse result %v != real val %v", realM19Map, testMsg.M_19)
+	}
+	realM20, err := m.DecodeMap(20, StringKeyDecoder, MessageValueDecoder)
+	if err != nil {
+		t.Fatalf("can not parse tag 20, err: %+v", err)
+	}
+	if len(realM20) != 1 || realM20[0].Key.val != "aa" || len(realM20[0].Value.val.(ProtoMessage).Values) != 0 {
+		t.Fatalf("parse result %+v != real val %v", realM20, testMsg.M_20)
+	}
+
+	// key的wire type与decoder不一致时视为未知字段跳过，使用默认值
+	elems, err := m.DecodeMap(18, StringKeyDecoder, StringValueDecoder)
+	if err != nil {
+		t.Fatalf("can not parse tag 18, err: %+v", err)
+	}
+	for _, elem := range elems {
+		if elem.Key.val != "" && elem.Key.val != "x" {
+			t.Fatalf("unexpected key %v", elem.Key.val)
+		}
+	}
+	// 包装内置decoder的自定义decoder按照声明的wire type处理缺省和不一致的字段
+	wrapped := NewKeyDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapKey, error) {
+		return Int32KeyDecoder.Decode(b)
+	})
+	wrappedM18, err := m.DecodeMap(18, wrapped, StringValueDecoder)
+	if err != nil {
+		t.Fatalf("can not parse tag 18, err: %+v", err)
+	}
+	if !reflect.DeepEqual(wrappedM18, realM18) {
+		t.Fatalf("parse result %v != %v", wrappedM18, realM18)
+	}
+}
+
+func TestDecodeGroup(t *testing.T) {
+	// proto2: optional group G = 2 { optional int32 a = 3; optional group Inner = 4 { optional string s = 5; } }
+	var bin []byte
+	bin = protowire.AppendTag(bin, 1, protowire.VarintType)
+	bin = protowire.AppendVarint(bin, 150)
+	bin = protowire.AppendTag(bin, 2, protowire.StartGroupType)
+	bin = protowire.AppendTag(bin, 3, protowire.VarintType)
+	bin = protowire.AppendVarint(bin, 7)
+	bin = protowire.AppendTag(bin, 4, protowire.StartGroupType)
+	bin = protowire.AppendTag(bin, 5, protowire.BytesType)
+	bin = protowire.AppendString(bin, "inner")
+	bin = protowire.AppendTag(bin, 4, protowire.EndGroupType)
+	bin = protowire.AppendTag(bin, 2, protowire.EndGroupType)
+
+	m, err := Decode(bin, NotSort)
+	if err != nil {
+		t.Fatalf("decode group message failed, err: %+v", err)
+	}
+	v2, err := m.GetData(2)
+	if err != nil {
+		t.Fatalf("can not get tag=2's data, err: %+v", err)
+	}
+	if v2.Type() != protowire.StartGroupType {
+		t.Fatalf("tag 2's type %v != %v", v2.Type(), protowire.StartGroupType)
+	}
+	if _, err := v2.DecodeEmbeddedMsg(NotSort); err != ErrTypeMismatch {
+		t.Fatalf("expect err %v, got %v", ErrTypeMismatch, err)
+	}
+	group, err := v2.DecodeGroup()
+	if err != nil {
+		t.Fatalf("can not parse tag 2, err: %+v", err)
+	}
+	v3, err := group.GetData(3)
+	if err != nil {
+		t.Fatalf("can not get tag=2_tag 3's data, err: %+v", err)
+	}
+	realA, err := v3.DecodeInt32()
+	if err != nil || realA != 7 {
+		t.Fatalf("parse result %d != real val %d, err: %+v", realA, 7, err)
+	}
+	v4, err := group.GetData(4)
+	if err != nil {
+		t.Fatalf("can not get tag=2_tag 4's data, err: %+v", err)
+	}
+	inner, err := v4.DecodeGroup()
+	if err != nil {
+		t.Fatalf("can not parse tag 2_tag 4, err: %+v", err)
+	}
+	v5, err := inner.GetData(5)
+	if err != nil {
+		t.Fatalf("can not get tag=2_tag 4_tag 5's data, err: %+v", err)
+	}
+	realS, err := v5.DecodeString()
+	if err != nil || realS != "inner" {
+		t.Fatalf("parse result %s != real val %s, err: %+v", realS, "inner", err)
+	}
+
+	result, err := Encode(m)
+	if err != nil {
+		t.Fatalf("encode group message failed, err: %+v", err)
+	}
+	if !bytes.Equal(result, bin) {
+		t.Fatalf("encode result %v != origin data %v", result, bin)
+	}
+	built, err := NewBuilder().Uint32(1, 150).
+		Group(2, NewBuilder().Int32(3, 7).Group(4, NewBuilder().String(5, "inner"))).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build group message, err: %+v", err)
+	}
+	if !bytes.Equal(built, bin) {
+		t.Fatalf("build result %v != origin data %v", built, bin)
+	}
+}
+
+func TestDecodeInvalidGroup(t *testing.T) {
+	// 结束tag与起始tag不一致
+	var bin []byte
+	bin = protowire.AppendTag(bin, 2, protowire.StartGroupType)
+	bin = protowire.AppendTag(bin, 3, protowire.EndGroupType)
+	if _, err := Decode(bin, NotSort); err == nil {
+		t.Fatalf("expect error for mismatched end group")
+	}
+	// 缺少起始tag
+	bin = protowire.AppendTag(nil, 2, protowire.EndGroupType)
+	if _, err := Decode(bin, NotSort); !errors.Is(err, ErrUnexpectedEndGroup) {
+		t.Fatalf("expect err %v, got %v", ErrUnexpectedEndGroup, err)
+	}
+}
+
+func TestDecodeSpan(t *testing.T) {
+	bin, err := NewBuilder().
+		Uint64(1, 150).
+		String(2, strings.Repeat("a", 200)).
+		Group(3, NewBuilder().Fixed32(4, 1)).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	m, err := DecodeWithOptions(bin, DecodeOptions{SortType: Asc, Spans: true})
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	expects := map[protowire.Number]FieldSpan{
+		1: {Offset: 0, TagLen: 1, PayloadLen: 2},
+		2: {Offset: 3, TagLen: 1, PrefixLen: 2, PayloadLen: 200},
+		3: {Offset: 206, TagLen: 1, PayloadLen: 5, EndTagLen: 1},
+	}
+	for tag, expect := range expects {
+		v, err := m.GetData(tag)
+		if err != nil {
+			t.Fatalf("can not get tag=%d's data, err: %+v", tag, err)
+		}
+		if v.Span() != expect {
+			t.Fatalf("tag %d's span %+v != expected %+v", tag, v.Span(), expect)
+		}
+	}
+	v3, _ := m.GetData(3)
+	if v3.Span().End() != len(bin) {
+		t.Fatalf("tag 3's end %d != data length %d", v3.Span().End(), len(bin))
+	}
+	group, err := v3.DecodeGroup()
+	if err != nil {
+		t.Fatalf("can not parse tag 3, err: %+v", err)
+	}
+	v4, err := group.GetData(4)
+	if err != nil {
+		t.Fatalf("can not get tag=3_tag 4's data, err: %+v", err)
+	}
+	// group内字段的偏移量相对于最外层数据
+	if expect := (FieldSpan{Offset: 207, TagLen: 1, PayloadLen: 4}); v4.Span() != expect {
+		t.Fatalf("tag 3_tag 4's span %+v != expected %+v", v4.Span(), expect)
+	}
+	if v4.Span().PayloadOffset() != 208 {
+		t.Fatalf("tag 3_tag 4's payload offset %d != %d", v4.Span().PayloadOffset(), 208)
+	}
+
+	// 默认不记录位置
+	plain, _ := Decode(bin, Asc)
+	for _, v := range plain.Values {
+		if v.Span() != (FieldSpan{}) {
+			t.Fatalf("tag %d's span %+v recorded without Spans option", v.tag, v.Span())
+		}
+	}
+	// 延迟解析的嵌套message同样记录位置
+	bin, err = NewBuilder().Uint64(1, 150).Message(2, NewBuilder().Fixed32(4, 1)).Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	m, err = DecodeWithOptions(bin, DecodeOptions{Lazy: true, Spans: true})
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	sub, err := m.Values[1].DecodeEmbeddedMsg(NotSort)
+	if err != nil {
+		t.Fatalf("can not parse tag 2, err: %+v", err)
+	}
+	if expect := (FieldSpan{Offset: 5, TagLen: 1, PayloadLen: 4}); sub.Values[0].Span() != expect {
+		t.Fatalf("tag 2_tag 4's span %+v != expected %+v", sub.Values[0].Span(), expect)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	inner, err := NewBuilder().Int32(1, 1).Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	// tag 3的长度超出数据范围
+	inner = append(inner, 0x1a, 0x05, 0x01)
+	bin, err := NewBuilder().
+		Uint64(1, 150).
+		Group(2, NewBuilder().Bytes(14, inner)).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	m, err := DecodeWithOptions(bin, DecodeOptions{Spans: true})
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	group, err := m.Values[1].DecodeGroup()
+	if err != nil {
+		t.Fatalf("can not parse tag 2, err: %+v", err)
+	}
+	_, err = group.Values[0].DecodeEmbeddedMsg(NotSort)
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expect *DecodeError, got %v", err)
+	}
+	// 1(tag 1) + 2(150) + 1(group tag) + 1(tag 14) + 1(length) + 2(tag 1=1)
+	expect := DecodeError{Offset: 8, Tag: 3, WireType: protowire.BytesType, Path: []protowire.Number{2, 14}}
+	if decodeErr.Offset != expect.Offset || decodeErr.Tag != expect.Tag || decodeErr.WireType != expect.WireType ||
+		!reflect.DeepEqual(decodeErr.Path, expect.Path) {
+		t.Fatalf("decode error %+v != expected %+v", decodeErr, expect)
+	}
+	if decodeErr.FieldPath() != "2.14.3" {
+		t.Fatalf("field path %s != %s", decodeErr.FieldPath(), "2.14.3")
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expect err %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+	if msg := "decode proto data failed at offset 8 (field 2.14.3, wire type 2): unexpected EOF"; err.Error() != msg {
+		t.Fatalf("error message %q != %q", err.Error(), msg)
+	}
+
+	// 未记录位置时偏移量和路径相对于嵌套message的数据
+	m, _ = Decode(bin, NotSort)
+	group, _ = m.Values[1].DecodeGroup()
+	_, err = group.Values[0].DecodeEmbeddedMsg(NotSort)
+	if !errors.As(err, &decodeErr) || decodeErr.Offset != 2 || decodeErr.FieldPath() != "3" {
+		t.Fatalf("unexpected decode error %+v", err)
+	}
+
+	// 整体解析时同样返回最内层出错字段的位置
+	bin, err = NewBuilder().Uint64(1, 150).Group(2, NewBuilder().Uint64(3, 1)).Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	// 将group内tag 3的wire type改为非法值7
+	bin[4] = byte(protowire.EncodeTag(3, 7))
+	_, err = Decode(bin, Asc)
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expect *DecodeError, got %v", err)
+	}
+	if decodeErr.Offset != 4 || decodeErr.FieldPath() != "2.3" || decodeErr.WireType != 7 {
+		t.Fatalf("unexpected decode error %+v", decodeErr)
+	}
+}
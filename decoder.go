@@ -1,9 +1,11 @@
 package codec
 
 import (
+	"fmt"
 	"math"
 
 	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // DecodeInt32 将底层数据尝试解析为int32
@@ -124,15 +126,87 @@ func (p ProtoValue) DecodeBytes() ([]byte, error) {
 }
 
 // DecodeEmbeddedMsg 将底层数据尝试解析为嵌套proto message
+//
+// 解析时开启了DecodeOptions.Spans时，嵌套message同样记录位置，解析失败时返回的*DecodeError的偏移量和路径同样相对于最外层的输入数据。
+// 延迟解析模式（DecodeOptions.Lazy）下，首次调用时解析并缓存结果，返回的ProtoMessage在多次调用间共享，调用方不应修改
 func (p ProtoValue) DecodeEmbeddedMsg(sortType MessageSortType) (ProtoMessage, error) {
 	val, err := p.parseLen()
 	if err != nil {
 		return ProtoMessage{}, err
 	}
-	return Decode(val, sortType)
+	if memo := p.memo(); memo != nil {
+		return memo.get(sortType, func() (ProtoMessage, error) {
+			return p.decodeEmbedded(val, DecodeOptions{SortType: sortType, Lazy: true, Index: memo.index})
+		})
+	}
+	return p.decodeEmbedded(val, DecodeOptions{SortType: sortType})
+}
+
+func (p ProtoValue) decodeEmbedded(b []byte, opts DecodeOptions) (ProtoMessage, error) {
+	base, path := p.spanPath()
+	opts.Spans = p.ext != nil && p.ext.spanned
+	m, err := decode(b, opts, base, path)
+	if err != nil {
+		return ProtoMessage{}, err
+	}
+	return m, nil
+}
+
+// DecodeGroup 将底层数据尝试解析为proto2 group
+//
+// group在Decode时已经按照外层的排序方式解析为嵌套ProtoMessage
+func (p ProtoValue) DecodeGroup() (ProtoMessage, error) {
+	return p.parseGroup()
+}
+
+// DecodeKind 按照指定的字段类型解析底层数据
+//
+// 返回值的类型与对应的DecodeXXX方法一致，enum返回int32，message和group返回未排序的ProtoMessage
+func (p ProtoValue) DecodeKind(kind protoreflect.Kind) (interface{}, error) {
+	switch kind {
+	case protoreflect.BoolKind:
+		return p.DecodeBool()
+	case protoreflect.EnumKind:
+		return p.DecodeEnum()
+	case protoreflect.Int32Kind:
+		return p.DecodeInt32()
+	case protoreflect.Sint32Kind:
+		return p.DecodeSint32()
+	case protoreflect.Uint32Kind:
+		return p.DecodeUint32()
+	case protoreflect.Int64Kind:
+		return p.DecodeInt64()
+	case protoreflect.Sint64Kind:
+		return p.DecodeSint64()
+	case protoreflect.Uint64Kind:
+		return p.DecodeUint64()
+	case protoreflect.Sfixed32Kind:
+		return p.DecodeSfixed32()
+	case protoreflect.Fixed32Kind:
+		return p.DecodeFixed32()
+	case protoreflect.FloatKind:
+		return p.DecodeFloat()
+	case protoreflect.Sfixed64Kind:
+		return p.DecodeSfixed64()
+	case protoreflect.Fixed64Kind:
+		return p.DecodeFixed64()
+	case protoreflect.DoubleKind:
+		return p.DecodeDouble()
+	case protoreflect.StringKind:
+		return p.DecodeString()
+	case protoreflect.BytesKind:
+		return p.DecodeBytes()
+	case protoreflect.MessageKind:
+		return p.DecodeEmbeddedMsg(NotSort)
+	case protoreflect.GroupKind:
+		return p.DecodeGroup()
+	}
+	return nil, fmt.Errorf("not support proto kind %v", kind)
 }
 
 // DecodeMap 将底层数据尝试解析为嵌套proto map类型
+//
+// 与proto.Unmarshal一致，entry中缺省的key或value使用默认值，重复出现时后出现的覆盖先出现的，未知字段被跳过
 func (p ProtoMessage) DecodeMap(tag protowire.Number, keyDec keyDecoder, valDec valueDecoder) ([]ProtoMapElem, error) {
 	idxs, err := p.GetRepeatedData(tag)
 	if err != nil {
@@ -140,40 +214,83 @@ func (p ProtoMessage) DecodeMap(tag protowire.Number, keyDec keyDecoder, valDec
 	}
 	m := make([]ProtoMapElem, 0, len(idxs))
 	for i := 0; i < len(idxs); i++ {
-		var key ProtoMapKey
-		var value ProtoMapValue
-		var err error
-
-		payload := p.Values[idxs[i]].val.([]byte)
-		payload, key, err = keyDec(payload)
-		if err != nil {
-			return nil, err
+		payload, ok := p.Values[idxs[i]].val.([]byte)
+		if !ok {
+			return nil, ErrTypeMismatch
 		}
-		_, value, err = valDec(payload)
+		elem, err := decodeMapEntry(payload, keyDec, valDec)
 		if err != nil {
 			return nil, err
 		}
-		m = append(m, ProtoMapElem{
-			Key:   ProtoMapKey(key),
-			Value: ProtoMapValue(value),
-		})
+		m = append(m, elem)
 	}
 	return m, nil
 }
 
 // DecodePackedRepeated 将底层数据尝试解析为[packed=true]的repeated字段数据
 //
-// 用于非repeated string, repeated bytes和repeated message
+// 用于非repeated string, repeated bytes和repeated message。
+// 与proto.Unmarshal一致，packed字段拆分为多段数据或混有未packed的元素时，按照出现顺序拼接后解码；
+// 未packed元素的wire type与decoder.WireType()不一致时返回ErrTypeMismatch
 func (p ProtoMessage) DecodePackedRepeated(tag protowire.Number, decoder packedRepeatedDecoder) (interface{}, error) {
 	idxs, err := p.GetRepeatedData(tag)
 	if err != nil {
 		return nil, err
 	}
-	if len(idxs) == 0 {
-		return decoder(ProtoValue{})
+	return p.decodePacked(tag, idxs, decoder)
+}
+
+// decodePacked 拼接idxs对应的数据后通过decoder解码
+func (p ProtoMessage) decodePacked(tag protowire.Number, idxs []int, decoder packedRepeatedDecoder) (interface{}, error) {
+	if len(idxs) == 1 && p.Values[idxs[0]]._type == protowire.BytesType {
+		// 数据是variant类型数据的集合，通过传入的decoder进行解码
+		return decoder.Decode(p.Values[idxs[0]])
 	}
-	// 数据是variant类型数据的集合，通过传入的decoder进行解码
-	return decoder(p.Values[idxs[0]])
+	payload, err := p.packedPayload(idxs, decoder.wireType)
+	if err != nil {
+		return nil, err
+	}
+	return decoder.Decode(ProtoValue{_type: protowire.BytesType, val: payload, tag: tag})
+}
+
+// packedPayload 将多段packed数据和未packed的元素按照出现顺序拼接为一段packed数据，wireType为未packed元素的wire type，BytesType表示不接受未packed的元素
+func (p ProtoMessage) packedPayload(idxs []int, wireType protowire.Type) ([]byte, error) {
+	payload := []byte{}
+	for _, i := range idxs {
+		v := p.Values[i]
+		if v._type != protowire.BytesType && v._type != wireType {
+			return nil, ErrTypeMismatch
+		}
+		switch v._type {
+		case protowire.BytesType:
+			val, err := v.parseLen()
+			if err != nil {
+				return nil, err
+			}
+			payload = append(payload, val...)
+		case protowire.VarintType:
+			val, err := v.parseVariant()
+			if err != nil {
+				return nil, err
+			}
+			payload = protowire.AppendVarint(payload, val)
+		case protowire.Fixed32Type:
+			val, err := v.parseI32()
+			if err != nil {
+				return nil, err
+			}
+			payload = protowire.AppendFixed32(payload, val)
+		case protowire.Fixed64Type:
+			val, err := v.parseI64()
+			if err != nil {
+				return nil, err
+			}
+			payload = protowire.AppendFixed64(payload, val)
+		default:
+			return nil, ErrTypeMismatch
+		}
+	}
+	return payload, nil
 }
 
 // DecodeUnpackedRepeated 将底层数据尝试解析为[packed=false]的repeated字段数据
@@ -274,3 +391,18 @@ func (p ProtoValue) parseI32() (uint32, error) {
 	}
 	return val, nil
 }
+
+func (p ProtoValue) parseGroup() (ProtoMessage, error) {
+	if p.val == nil {
+		// 零值情况
+		return ProtoMessage{}, nil
+	}
+	if p._type != protowire.StartGroupType {
+		return ProtoMessage{}, ErrTypeMismatch
+	}
+	val, ok := p.val.(ProtoMessage)
+	if !ok {
+		return ProtoMessage{}, ErrAssertTypeFailed
+	}
+	return val, nil
+}
@@ -0,0 +1,230 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// DefaultMaxRecordSize DelimitedOptions.MaxRecordSize为0时单条记录的最大字节数，与protodelim一致
+const DefaultMaxRecordSize = 4 << 20
+
+var (
+	ErrRecordTooLarge = errors.New("record size exceeds limit")
+)
+
+// DelimitedOptions DelimitedReader和DelimitedWriter的选项
+type DelimitedOptions struct {
+	// MaxRecordSize 单条记录（不包括长度前缀）的最大字节数，0表示使用DefaultMaxRecordSize，负数表示不限制
+	MaxRecordSize int
+	// Resync 记录损坏（长度超出限制、数据不完整或无法解析）时，丢弃记录的第一个字节后重新查找下一条记录
+	Resync bool
+	// SortType 解析记录时使用的排序方式
+	SortType MessageSortType
+}
+
+func (o DelimitedOptions) maxRecordSize() int {
+	if o.MaxRecordSize == 0 {
+		return DefaultMaxRecordSize
+	}
+	return o.MaxRecordSize
+}
+
+// DelimitedReader 读取varint长度前缀分隔的多条message（writeDelimitedTo/protodelim格式）
+//
+// 典型用法：
+//
+//	r := NewDelimitedReader(f, DelimitedOptions{Resync: true})
+//	for r.Next() {
+//		msg := r.Message()
+//	}
+//	if err := r.Err(); err != nil {
+//	}
+type DelimitedReader struct {
+	r    *bufio.Reader
+	opts DelimitedOptions
+	// pending 重新查找记录时退回的数据，优先于r读取
+	pending []byte
+	// pos 已读取（不包括pending）的字节数
+	pos     int
+	offset  int
+	skipped int
+	msg     ProtoMessage
+	err     error
+}
+
+// NewDelimitedReader 创建从r中读取长度前缀分隔message的DelimitedReader
+func NewDelimitedReader(r io.Reader, opts DelimitedOptions) *DelimitedReader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &DelimitedReader{r: br, opts: opts}
+}
+
+// Next 读取并解析下一条记录，数据结束或出错时返回false，通过Err获取错误
+func (r *DelimitedReader) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	for {
+		r.offset = r.pos - len(r.pending)
+		raw, msg, err := r.readRecord()
+		if err == nil {
+			r.msg = msg
+			return true
+		}
+		if err == io.EOF && len(raw) == 0 {
+			r.err = io.EOF
+			return false
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if !r.opts.Resync || len(raw) == 0 {
+			r.err = fmt.Errorf("read record at offset %d failed: %w", r.offset, err)
+			return false
+		}
+		// 丢弃记录的第一个字节，剩余数据重新作为下一条记录的开始
+		r.pending = append(raw[1:len(raw):len(raw)], r.pending...)
+		r.skipped++
+	}
+}
+
+// Message 返回当前记录解析得到的message
+func (r *DelimitedReader) Message() ProtoMessage {
+	return r.msg
+}
+
+// Offset 返回当前记录的长度前缀在输入数据中的偏移量
+func (r *DelimitedReader) Offset() int {
+	return r.offset
+}
+
+// Skipped 返回重新查找记录时丢弃的字节数
+func (r *DelimitedReader) Skipped() int {
+	return r.skipped
+}
+
+// Err 返回读取过程中遇到的错误，正常结束时返回nil
+func (r *DelimitedReader) Err() error {
+	if r.err == io.EOF {
+		return nil
+	}
+	return r.err
+}
+
+// readRecord 读取一条记录，同时返回已读取的原始数据（长度前缀和数据）以便重新查找记录
+func (r *DelimitedReader) readRecord() ([]byte, ProtoMessage, error) {
+	raw := make([]byte, 0, binary.MaxVarintLen64)
+	for {
+		c, err := r.readByte()
+		if err != nil {
+			return raw, ProtoMessage{}, err
+		}
+		raw = append(raw, c)
+		if c < 0x80 || len(raw) == binary.MaxVarintLen64 {
+			break
+		}
+	}
+	size, n := protowire.ConsumeVarint(raw)
+	if n < 0 {
+		return raw, ProtoMessage{}, protowire.ParseError(n)
+	}
+	if max := r.opts.maxRecordSize(); max >= 0 && size > uint64(max) {
+		return raw, ProtoMessage{}, fmt.Errorf("%w: %d > %d", ErrRecordTooLarge, size, max)
+	}
+	// 不限制记录大小时，长度同样不能超过int的范围
+	if size > uint64(math.MaxInt-n) {
+		return raw, ProtoMessage{}, fmt.Errorf("%w: %d", ErrRecordTooLarge, size)
+	}
+	for uint64(len(raw)-n) < size {
+		chunk, err := r.read(int(size) - (len(raw) - n))
+		raw = append(raw, chunk...)
+		if err != nil {
+			return raw, ProtoMessage{}, err
+		}
+	}
+	msg, err := Decode(raw[n:], r.opts.SortType)
+	if err != nil {
+		return raw, ProtoMessage{}, err
+	}
+	return raw, msg, nil
+}
+
+func (r *DelimitedReader) readByte() (byte, error) {
+	if len(r.pending) > 0 {
+		c := r.pending[0]
+		r.pending = r.pending[1:]
+		return c, nil
+	}
+	c, err := r.r.ReadByte()
+	if err == nil {
+		r.pos++
+	}
+	return c, err
+}
+
+// read 读取最多n字节数据，每次读取的数据量受缓冲区大小限制，避免错误的长度导致过大的内存分配
+func (r *DelimitedReader) read(n int) ([]byte, error) {
+	if len(r.pending) > 0 {
+		if n > len(r.pending) {
+			n = len(r.pending)
+		}
+		b := r.pending[:n]
+		r.pending = r.pending[n:]
+		return b, nil
+	}
+	if n > r.r.Size() {
+		n = r.r.Size()
+	}
+	b := make([]byte, n)
+	m, err := io.ReadFull(r.r, b)
+	r.pos += m
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return b[:m], err
+}
+
+// DelimitedWriter 写入varint长度前缀分隔的多条message，与DelimitedReader对应
+type DelimitedWriter struct {
+	w    io.Writer
+	opts DelimitedOptions
+}
+
+// NewDelimitedWriter 创建向w写入长度前缀分隔message的DelimitedWriter，opts中只使用MaxRecordSize
+func NewDelimitedWriter(w io.Writer, opts DelimitedOptions) *DelimitedWriter {
+	return &DelimitedWriter{w: w, opts: opts}
+}
+
+// WriteMessage 编码m并写入长度前缀和数据
+func (w *DelimitedWriter) WriteMessage(m ProtoMessage) error {
+	b, err := Encode(m)
+	if err != nil {
+		return err
+	}
+	return w.WriteRecord(b)
+}
+
+// WriteRecord 写入已编码的message数据及其长度前缀
+func (w *DelimitedWriter) WriteRecord(b []byte) error {
+	if err := w.checkSize(len(b)); err != nil {
+		return err
+	}
+	out := protowire.AppendVarint(make([]byte, 0, protowire.SizeVarint(uint64(len(b)))+len(b)), uint64(len(b)))
+	_, err := w.w.Write(append(out, b...))
+	return err
+}
+
+func (w *DelimitedWriter) checkSize(size int) error {
+	if max := w.opts.maxRecordSize(); max >= 0 && size > max {
+		return fmt.Errorf("%w: %d > %d", ErrRecordTooLarge, size, max)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestDelimitedReadWrite(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewDelimitedWriter(&buf, DelimitedOptions{})
+	var expects []ProtoMessage
+	for i := 0; i < 3; i++ {
+		m := NewBuilder().Int32(1, int32(i)).String(2, "record").Build()
+		if err := w.WriteMessage(m); err != nil {
+			t.Fatalf("write record %d failed, err: %+v", i, err)
+		}
+		expects = append(expects, m)
+	}
+	// 空记录
+	if err := w.WriteRecord(nil); err != nil {
+		t.Fatalf("write empty record failed, err: %+v", err)
+	}
+
+	r := NewDelimitedReader(&buf, DelimitedOptions{})
+	var offsets []int
+	var i int
+	for r.Next() {
+		offsets = append(offsets, r.Offset())
+		if i == len(expects) {
+			if len(r.Message().Values) != 0 {
+				t.Fatalf("expect empty record, got %+v", r.Message())
+			}
+			i++
+			continue
+		}
+		for j, v := range r.Message().Values {
+			if v.tag != expects[i].Values[j].tag || !reflect.DeepEqual(v.val, expects[i].Values[j].val) {
+				t.Fatalf("record %d value %+v != expected %+v", i, v, expects[i].Values[j])
+			}
+		}
+		i++
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("read records failed, err: %+v", err)
+	}
+	if i != len(expects)+1 {
+		t.Fatalf("record count %d != %d", i, len(expects)+1)
+	}
+	if !reflect.DeepEqual(offsets, []int{0, 11, 22, 33}) {
+		t.Fatalf("record offsets %v != %v", offsets, []int{0, 11, 22, 33})
+	}
+}
+
+func TestDelimitedMaxRecordSize(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewDelimitedWriter(&buf, DelimitedOptions{MaxRecordSize: 4})
+	if err := w.WriteRecord(make([]byte, 5)); !errors.Is(err, ErrRecordTooLarge) {
+		t.Fatalf("expect err %v, got %v", ErrRecordTooLarge, err)
+	}
+	w = NewDelimitedWriter(&buf, DelimitedOptions{})
+	if err := w.WriteMessage(NewBuilder().Bytes(1, make([]byte, 10)).Build()); err != nil {
+		t.Fatalf("write record failed, err: %+v", err)
+	}
+	r := NewDelimitedReader(bytes.NewReader(buf.Bytes()), DelimitedOptions{MaxRecordSize: 4})
+	if r.Next() {
+		t.Fatalf("expect error for too large record")
+	}
+	if !errors.Is(r.Err(), ErrRecordTooLarge) {
+		t.Fatalf("expect err %v, got %v", ErrRecordTooLarge, r.Err())
+	}
+	r = NewDelimitedReader(bytes.NewReader(buf.Bytes()), DelimitedOptions{MaxRecordSize: -1})
+	if !r.Next() {
+		t.Fatalf("read record failed, err: %+v", r.Err())
+	}
+	// 不限制记录大小时，超出int范围的长度同样返回错误
+	for _, resync := range []bool{false, true} {
+		huge := protowire.AppendVarint(nil, math.MaxUint64)
+		r = NewDelimitedReader(bytes.NewReader(append(huge, 1, 2, 3)), DelimitedOptions{MaxRecordSize: -1, Resync: resync})
+		for r.Next() {
+		}
+		if !resync && !errors.Is(r.Err(), ErrRecordTooLarge) {
+			t.Fatalf("expect err %v, got %v", ErrRecordTooLarge, r.Err())
+		}
+	}
+}
+
+func TestDelimitedResync(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewDelimitedWriter(&buf, DelimitedOptions{})
+	if err := w.WriteMessage(NewBuilder().Int32(1, 1).Build()); err != nil {
+		t.Fatalf("write record failed, err: %+v", err)
+	}
+	// 长度前缀超出限制的损坏记录
+	buf.Write([]byte{0xff, 0xff, 0xff, 0x7f})
+	if err := w.WriteMessage(NewBuilder().Int32(1, 2).Build()); err != nil {
+		t.Fatalf("write record failed, err: %+v", err)
+	}
+	// 数据无法解析的损坏记录
+	buf.Write([]byte{0x01, 0x07})
+	if err := w.WriteMessage(NewBuilder().Int32(1, 3).Build()); err != nil {
+		t.Fatalf("write record failed, err: %+v", err)
+	}
+	bin := buf.Bytes()
+
+	r := NewDelimitedReader(bytes.NewReader(bin), DelimitedOptions{})
+	if !r.Next() {
+		t.Fatalf("read record failed, err: %+v", r.Err())
+	}
+	if r.Next() || !errors.Is(r.Err(), ErrRecordTooLarge) {
+		t.Fatalf("expect err %v, got %v", ErrRecordTooLarge, r.Err())
+	}
+
+	r = NewDelimitedReader(bytes.NewReader(bin), DelimitedOptions{Resync: true})
+	var vals []int32
+	var offsets []int
+	for r.Next() {
+		msg := r.Message()
+		v, err := msg.GetData(1)
+		if err != nil {
+			t.Fatalf("can not get tag=1's data, err: %+v", err)
+		}
+		val, err := v.DecodeInt32()
+		if err != nil {
+			t.Fatalf("parse tag 1 failed, err: %+v", err)
+		}
+		vals = append(vals, val)
+		offsets = append(offsets, r.Offset())
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("read records failed, err: %+v", err)
+	}
+	if !reflect.DeepEqual(vals, []int32{1, 2, 3}) {
+		t.Fatalf("record values %v != %v", vals, []int32{1, 2, 3})
+	}
+	if !reflect.DeepEqual(offsets, []int{0, 7, 12}) {
+		t.Fatalf("record offsets %v != %v", offsets, []int{0, 7, 12})
+	}
+	if r.Skipped() != 6 {
+		t.Fatalf("skipped bytes %d != %d", r.Skipped(), 6)
+	}
+
+	// 截断的记录
+	r = NewDelimitedReader(bytes.NewReader(bin[:2]), DelimitedOptions{})
+	if r.Next() || !errors.Is(r.Err(), io.ErrUnexpectedEOF) {
+		t.Fatalf("expect err %v, got %v", io.ErrUnexpectedEOF, r.Err())
+	}
+}
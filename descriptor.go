@@ -0,0 +1,296 @@
+package codec
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// TypedMessage 根据MessageDescriptor解析得到的带名字和类型的message
+type TypedMessage struct {
+	Desc protoreflect.MessageDescriptor
+	// Fields 描述中存在的字段，按照在二进制流中首次出现的顺序排列
+	Fields []TypedField
+	// Unknown 描述中不存在或wire type与描述不匹配的字段，保留原始数据
+	Unknown []ProtoValue
+}
+
+type TypedField struct {
+	Desc protoreflect.FieldDescriptor
+	/*
+		由字段类型决定，可能为：
+
+		int32/int64/uint32/uint64/bool/float32/float64/string/[]byte（标量类型）
+
+		EnumValue（enum）
+
+		*TypedMessage（message/group）
+
+		[]interface{}（repeated，元素为上述类型）
+
+		map[interface{}]interface{}（map）
+	*/
+	Value interface{}
+}
+
+// Name 返回字段名
+func (f TypedField) Name() string {
+	return string(f.Desc.Name())
+}
+
+// Number 返回字段tag
+func (f TypedField) Number() protowire.Number {
+	return f.Desc.Number()
+}
+
+// EnumValue enum字段的值，Name为空表示该值未在描述中定义
+type EnumValue struct {
+	Number int32
+	Name   string
+}
+
+func (e EnumValue) String() string {
+	if e.Name == "" {
+		return fmt.Sprintf("%d", e.Number)
+	}
+	return e.Name
+}
+
+// Get 根据字段名获取字段值
+func (m *TypedMessage) Get(name string) (interface{}, bool) {
+	for i := range m.Fields {
+		if m.Fields[i].Name() == name {
+			return m.Fields[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// GetByNumber 根据tag获取字段值
+func (m *TypedMessage) GetByNumber(tag protowire.Number) (interface{}, bool) {
+	for i := range m.Fields {
+		if m.Fields[i].Number() == tag {
+			return m.Fields[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// DecodeWithDescriptor 根据MessageDescriptor解析proto二进制流数据
+//
+// 描述中不存在的tag保留在TypedMessage.Unknown中
+func DecodeWithDescriptor(b []byte, md protoreflect.MessageDescriptor) (*TypedMessage, error) {
+	m, err := Decode(b, NotSort)
+	if err != nil {
+		return nil, err
+	}
+	return newTypedMessage(m, md)
+}
+
+func newTypedMessage(p ProtoMessage, md protoreflect.MessageDescriptor) (*TypedMessage, error) {
+	m := &TypedMessage{Desc: md}
+	// 字段在Fields中的位置
+	pos := make(map[protowire.Number]int)
+	// singular嵌套message字段合并后的数据
+	merged := make(map[protowire.Number]ProtoMessage)
+	for _, v := range p.Values {
+		fd := md.Fields().ByNumber(v.tag)
+		if fd == nil {
+			m.Unknown = append(m.Unknown, v)
+			continue
+		}
+		i, ok := pos[v.tag]
+		if !ok {
+			i = len(m.Fields)
+			pos[v.tag] = i
+			m.Fields = append(m.Fields, TypedField{Desc: fd, Value: emptyTypedValue(fd)})
+		}
+		var err error
+		var known bool
+		switch {
+		case fd.IsMap():
+			known, err = decodeTypedMapEntry(v, fd, m.Fields[i].Value.(map[interface{}]interface{}))
+		case fd.IsList():
+			var list []interface{}
+			list, known, err = decodeTypedList(v, fd, m.Fields[i].Value.([]interface{}))
+			if known {
+				m.Fields[i].Value = list
+			}
+		case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+			// 嵌套message字段出现多次时合并
+			known = v._type == kindWireType(fd.Kind())
+			if known {
+				var sub interface{}
+				if sub, err = v.DecodeKind(fd.Kind()); err == nil {
+					msg := merged[v.tag]
+					msg.Merge(sub.(ProtoMessage))
+					merged[v.tag] = msg
+					m.Fields[i].Value, err = newTypedMessage(msg, fd.Message())
+				}
+			}
+		default:
+			// 标量字段出现多次时以最后一次为准
+			var val interface{}
+			known = v._type == kindWireType(fd.Kind())
+			if known {
+				val, err = decodeTypedScalar(v, fd)
+				m.Fields[i].Value = val
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode field %s failed: %w", fd.FullName(), err)
+		}
+		if !known {
+			m.Unknown = append(m.Unknown, v)
+		}
+	}
+	// 仅包含wire type不匹配数据的字段不应出现在Fields中
+	fields := m.Fields[:0]
+	for _, f := range m.Fields {
+		if f.Value != nil {
+			fields = append(fields, f)
+		}
+	}
+	m.Fields = fields
+	return m, nil
+}
+
+// emptyTypedValue 返回字段解析前的初始值，singular字段为nil
+func emptyTypedValue(fd protoreflect.FieldDescriptor) interface{} {
+	switch {
+	case fd.IsMap():
+		return map[interface{}]interface{}{}
+	case fd.IsList():
+		return []interface{}{}
+	}
+	return nil
+}
+
+func decodeTypedList(v ProtoValue, fd protoreflect.FieldDescriptor, list []interface{}) ([]interface{}, bool, error) {
+	wireType := kindWireType(fd.Kind())
+	if v._type == wireType {
+		val, err := decodeTypedScalar(v, fd)
+		if err != nil {
+			return nil, true, err
+		}
+		return append(list, val), true, nil
+	}
+	if v._type != protowire.BytesType || !isPackableKind(fd.Kind()) {
+		return nil, false, nil
+	}
+	// [packed=true]的数据，逐个取出元素后按照标量解析
+	payload, err := v.parseLen()
+	if err != nil {
+		return nil, true, err
+	}
+	for len(payload) > 0 {
+		var val interface{}
+		var n int
+		switch wireType {
+		case protowire.VarintType:
+			val, n = protowire.ConsumeVarint(payload)
+		case protowire.Fixed32Type:
+			val, n = protowire.ConsumeFixed32(payload)
+		case protowire.Fixed64Type:
+			val, n = protowire.ConsumeFixed64(payload)
+		}
+		if n < 0 {
+			return nil, true, protowire.ParseError(n)
+		}
+		elem, err := decodeTypedScalar(ProtoValue{_type: wireType, val: val, tag: v.tag}, fd)
+		if err != nil {
+			return nil, true, err
+		}
+		list = append(list, elem)
+		payload = payload[n:]
+	}
+	return list, true, nil
+}
+
+func decodeTypedMapEntry(v ProtoValue, fd protoreflect.FieldDescriptor, m map[interface{}]interface{}) (bool, error) {
+	if v._type != protowire.BytesType {
+		return false, nil
+	}
+	raw, err := v.DecodeEmbeddedMsg(NotSort)
+	if err != nil {
+		return true, err
+	}
+	entry, err := newTypedMessage(raw, fd.Message())
+	if err != nil {
+		return true, err
+	}
+	key, ok := entry.GetByNumber(keyTag)
+	if !ok {
+		key = zeroTypedValue(fd.MapKey())
+	}
+	val, ok := entry.GetByNumber(valTag)
+	if !ok {
+		val = zeroTypedValue(fd.MapValue())
+	}
+	m[key] = val
+	return true, nil
+}
+
+func decodeTypedScalar(v ProtoValue, fd protoreflect.FieldDescriptor) (interface{}, error) {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		num, err := v.DecodeEnum()
+		if err != nil {
+			return nil, err
+		}
+		e := EnumValue{Number: num}
+		if ev := fd.Enum().Values().ByNumber(protoreflect.EnumNumber(num)); ev != nil {
+			e.Name = string(ev.Name())
+		}
+		return e, nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		val, err := v.DecodeKind(fd.Kind())
+		if err != nil {
+			return nil, err
+		}
+		return newTypedMessage(val.(ProtoMessage), fd.Message())
+	}
+	return v.DecodeKind(fd.Kind())
+}
+
+// zeroTypedValue 返回map entry中缺失key或value时的默认值
+func zeroTypedValue(fd protoreflect.FieldDescriptor) interface{} {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		e := EnumValue{Number: int32(fd.Default().Enum())}
+		if ev := fd.DefaultEnumValue(); ev != nil {
+			e.Name = string(ev.Name())
+		}
+		return e
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return &TypedMessage{Desc: fd.Message()}
+	}
+	return fd.Default().Interface()
+}
+
+// kindWireType 返回字段类型对应的wire type
+func kindWireType(kind protoreflect.Kind) protowire.Type {
+	switch kind {
+	case protoreflect.BoolKind, protoreflect.EnumKind,
+		protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Uint32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Uint64Kind:
+		return protowire.VarintType
+	case protoreflect.Sfixed32Kind, protoreflect.Fixed32Kind, protoreflect.FloatKind:
+		return protowire.Fixed32Type
+	case protoreflect.Sfixed64Kind, protoreflect.Fixed64Kind, protoreflect.DoubleKind:
+		return protowire.Fixed64Type
+	case protoreflect.GroupKind:
+		return protowire.StartGroupType
+	}
+	return protowire.BytesType
+}
+
+// isPackableKind 判断字段类型是否可以使用[packed=true]编码
+func isPackableKind(kind protoreflect.Kind) bool {
+	switch kind {
+	case protoreflect.StringKind, protoreflect.BytesKind, protoreflect.MessageKind, protoreflect.GroupKind:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package codec
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/KarKLi/protobuf-golang-codec/internal/proto3_test"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func checkTypedEmbeededEqual(t *testing.T, v interface{}, m *proto3_test.Embeeded) {
+	msg, ok := v.(*TypedMessage)
+	if !ok {
+		t.Fatalf("expect *TypedMessage, got %T", v)
+	}
+	expect := map[string]interface{}{"i_1": m.I_1, "f_2": m.F_2, "s_3": m.S_3, "f_4": m.F_4}
+	for name, val := range expect {
+		realVal, ok := msg.Get(name)
+		if !ok {
+			// proto3零值不会写入二进制流
+			if !reflect.ValueOf(val).IsZero() {
+				t.Fatalf("field %s not found", name)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(realVal, val) {
+			t.Fatalf("field %s parse result %v != real val %v", name, realVal, val)
+		}
+	}
+}
+
+func TestDecodeWithDescriptor(t *testing.T) {
+	bin, err := proto.Marshal(testMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	// 追加一个描述中不存在的字段
+	bin = protowire.AppendTag(bin, 100, protowire.VarintType)
+	bin = protowire.AppendVarint(bin, 1)
+	m, err := DecodeWithDescriptor(bin, testMsg.ProtoReflect().Descriptor())
+	if err != nil {
+		t.Fatalf("decode test proto message with descriptor failed, err: %+v", err)
+	}
+	expect := map[string]interface{}{
+		"i_1":  testMsg.I_1,
+		"i_2":  testMsg.I_2,
+		"u_3":  testMsg.U_3,
+		"u_4":  testMsg.U_4,
+		"s_5":  testMsg.S_5,
+		"s_6":  testMsg.S_6,
+		"f_9":  testMsg.F_9,
+		"s_10": testMsg.S_10,
+		"d_11": testMsg.D_11,
+		"s_12": testMsg.S_12,
+		"b_13": testMsg.B_13,
+		"f_15": testMsg.F_15,
+		"s_16": testMsg.S_16,
+		"f_17": testMsg.F_17,
+	}
+	for name, val := range expect {
+		realVal, ok := m.Get(name)
+		if !ok {
+			t.Fatalf("field %s not found", name)
+		}
+		if !reflect.DeepEqual(realVal, val) {
+			t.Fatalf("field %s parse result %v != real val %v", name, realVal, val)
+		}
+	}
+	if testMsg.E_8 != proto3_test.TestEnum_ZERO {
+		e8, _ := m.Get("e_8")
+		if e8.(EnumValue).Name != testMsg.E_8.String() {
+			t.Fatalf("field e_8 parse result %v != real val %v", e8, testMsg.E_8)
+		}
+	}
+	m14, _ := m.Get("m_14")
+	checkTypedEmbeededEqual(t, m14, testMsg.M_14)
+	if len(m.Unknown) != 1 || m.Unknown[0].Tag() != 100 {
+		t.Fatalf("unknown fields %v, expect tag 100", m.Unknown)
+	}
+}
+
+func TestDecodeRepeatedWithDescriptor(t *testing.T) {
+	for _, msg := range []proto.Message{testPackedRepeatedMsg, testUnpackedRepeatedMsg} {
+		bin, err := proto.Marshal(msg)
+		if err != nil {
+			t.Fatalf("can not marshal test proto message, err: %+v", err)
+		}
+		m, err := DecodeWithDescriptor(bin, msg.ProtoReflect().Descriptor())
+		if err != nil {
+			t.Fatalf("decode test proto message with descriptor failed, err: %+v", err)
+		}
+		i1, _ := m.Get("i_1")
+		if len(i1.([]interface{})) != 4 || i1.([]interface{})[1] != int32(2147483647) {
+			t.Fatalf("field i_1 parse result %v", i1)
+		}
+		e8, _ := m.Get("e_8")
+		names := []string{}
+		for _, e := range e8.([]interface{}) {
+			names = append(names, e.(EnumValue).Name)
+		}
+		if !reflect.DeepEqual(names, []string{"ZERO", "ONE", "TWO"}) {
+			t.Fatalf("field e_8 parse result %v", names)
+		}
+		if len(m.Unknown) != 0 {
+			t.Fatalf("unexpected unknown fields %v", m.Unknown)
+		}
+	}
+
+	bin, err := proto.Marshal(testUnpackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	m, err := DecodeWithDescriptor(bin, testUnpackedRepeatedMsg.ProtoReflect().Descriptor())
+	if err != nil {
+		t.Fatalf("decode test proto message with descriptor failed, err: %+v", err)
+	}
+	m17, _ := m.Get("m_17")
+	for i, v := range m17.([]interface{}) {
+		checkTypedEmbeededEqual(t, v, testUnpackedRepeatedMsg.M_17[i])
+	}
+	m18, _ := m.Get("m_18")
+	for k, v := range testUnpackedRepeatedMsg.M_18 {
+		if m18.(map[interface{}]interface{})[k] != v {
+			t.Fatalf("field m_18[%d] parse result %v != real val %v", k, m18.(map[interface{}]interface{})[k], v)
+		}
+	}
+	m20, _ := m.Get("m_20")
+	if len(m20.(map[interface{}]interface{})) != len(testUnpackedRepeatedMsg.M_20) {
+		t.Fatalf("field m_20 parse result %v != real val %v", m20, testUnpackedRepeatedMsg.M_20)
+	}
+	for k, v := range testUnpackedRepeatedMsg.M_20 {
+		checkTypedEmbeededEqual(t, m20.(map[interface{}]interface{})[k], v)
+	}
+}
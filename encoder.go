@@ -0,0 +1,98 @@
+package codec
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Encode 将ProtoMessage按照Values中的顺序重新编码为proto二进制流数据
+func Encode(m ProtoMessage) ([]byte, error) {
+	return m.Marshal()
+}
+
+// Marshal 将ProtoMessage重新编码为proto二进制流数据
+//
+// 未排序（NotSort）解码得到的ProtoMessage重新编码后与原始输入逐字节一致
+func (p ProtoMessage) Marshal() ([]byte, error) {
+	return p.appendTo(make([]byte, 0, p.size()))
+}
+
+func (p ProtoMessage) appendTo(b []byte) ([]byte, error) {
+	var err error
+	for i := range p.Values {
+		b, err = p.Values[i].appendTo(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// size 估算编码后的长度，仅用于预分配
+func (p ProtoMessage) size() int {
+	n := 0
+	for i := range p.Values {
+		n += protowire.SizeTag(p.Values[i].tag)
+		switch p.Values[i]._type {
+		case protowire.VarintType:
+			val, _ := p.Values[i].val.(uint64)
+			n += protowire.SizeVarint(val)
+		case protowire.Fixed32Type:
+			n += protowire.SizeFixed32()
+		case protowire.Fixed64Type:
+			n += protowire.SizeFixed64()
+		case protowire.BytesType:
+			val, _ := p.Values[i].val.([]byte)
+			n += protowire.SizeBytes(len(val))
+		case protowire.StartGroupType:
+			val, _ := p.Values[i].val.(ProtoMessage)
+			n += val.size() + protowire.SizeTag(p.Values[i].tag)
+		}
+	}
+	return n
+}
+
+// appendTo 将单个字段（tag+数据）追加编码到b中
+func (p ProtoValue) appendTo(b []byte) ([]byte, error) {
+	b = protowire.AppendTag(b, p.tag, p._type)
+	switch p._type {
+	case protowire.VarintType:
+		val, err := p.parseVariant()
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendVarint(b, val)
+	case protowire.Fixed32Type:
+		val, err := p.parseI32()
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendFixed32(b, val)
+	case protowire.Fixed64Type:
+		val, err := p.parseI64()
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendFixed64(b, val)
+	case protowire.BytesType:
+		val, err := p.parseLen()
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendBytes(b, val)
+	case protowire.StartGroupType:
+		val, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		b, err = val.appendTo(b)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, p.tag, protowire.EndGroupType)
+	default:
+		return nil, fmt.Errorf("not support proto data type %d", p._type)
+	}
+	return b, nil
+}
@@ -0,0 +1,59 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/KarKLi/protobuf-golang-codec/internal/proto3_test"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, msg := range []proto.Message{testMsg, testPackedRepeatedMsg, testUnpackedRepeatedMsg} {
+		bin, err := proto.Marshal(msg)
+		if err != nil {
+			t.Fatalf("can not marshal test proto message, err: %+v", err)
+		}
+		m, err := Decode(bin, NotSort)
+		if err != nil {
+			t.Fatalf("decode test proto message failed, err: %+v", err)
+		}
+		result, err := Encode(m)
+		if err != nil {
+			t.Fatalf("encode test proto message failed, err: %+v", err)
+		}
+		if !bytes.Equal(result, bin) {
+			t.Fatalf("encode result %v != origin data %v", result, bin)
+		}
+	}
+}
+
+func TestEncodeModifiedMessage(t *testing.T) {
+	bin, err := proto.Marshal(testMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	m, err := Decode(bin, Asc)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	// 修改tag 12的字符串后重新编码
+	for i := range m.Values {
+		if m.Values[i].tag == 12 {
+			m.Values[i].val = []byte("modified")
+		}
+	}
+	result, err := m.Marshal()
+	if err != nil {
+		t.Fatalf("encode test proto message failed, err: %+v", err)
+	}
+	realMsg := &proto3_test.Msg{}
+	if err := proto.Unmarshal(result, realMsg); err != nil {
+		t.Fatalf("can not unmarshal encode result, err: %+v", err)
+	}
+	expectMsg := proto.Clone(testMsg).(*proto3_test.Msg)
+	expectMsg.S_12 = "modified"
+	if !proto.Equal(realMsg, expectMsg) {
+		t.Fatalf("encode result %v != expected val %v", realMsg, expectMsg)
+	}
+}
@@ -0,0 +1,61 @@
+package codec
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// DecodeError Decode解析失败时返回的错误，记录出错字段的位置
+type DecodeError struct {
+	// Offset 出错字段tag的起始偏移量，相对于最外层的输入数据
+	Offset int
+	// Tag 出错字段的tag，tag本身无法解析时为0
+	Tag protowire.Number
+	// WireType 出错字段的wire type，tag本身无法解析时为0
+	WireType protowire.Type
+	// Path 出错字段所在message的tag路径，最外层message为空，例如[14 3]表示tag 14中tag 3的message
+	Path []protowire.Number
+	// Err 底层错误，例如protowire.ParseError
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "decode proto data failed at offset %d", e.Offset)
+	if e.Tag > 0 {
+		fmt.Fprintf(&sb, " (field %s, wire type %d)", e.FieldPath(), e.WireType)
+	} else if len(e.Path) > 0 {
+		fmt.Fprintf(&sb, " (in field %s)", formatPath(e.Path))
+	}
+	sb.WriteString(": ")
+	sb.WriteString(e.Err.Error())
+	return sb.String()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// FieldPath 返回出错字段的完整路径，例如14.3，tag本身无法解析时返回所在message的路径
+func (e *DecodeError) FieldPath() string {
+	if e.Tag > 0 {
+		return formatPath(appendPath(e.Path, e.Tag))
+	}
+	return formatPath(e.Path)
+}
+
+// appendPath 返回path追加tag后的新路径，不修改path本身
+func appendPath(path []protowire.Number, tag protowire.Number) []protowire.Number {
+	return append(path[:len(path):len(path)], tag)
+}
+
+func formatPath(path []protowire.Number) string {
+	elems := make([]string, 0, len(path))
+	for _, tag := range path {
+		elems = append(elems, strconv.Itoa(int(tag)))
+	}
+	return strings.Join(elems, ".")
+}
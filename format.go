@@ -0,0 +1,106 @@
+package codec
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// rawRecursionLimit 与protoc打印unknown fields时的递归深度限制一致，超过该深度的嵌套数据按字符串输出
+const rawRecursionLimit = 10
+
+// FormatRaw 将ProtoMessage格式化为与protoc --decode_raw一致的文本
+//
+// BytesType数据能够解析为message时按嵌套message输出，否则按转义后的字符串输出；
+// fixed32/fixed64按十六进制输出
+func FormatRaw(m ProtoMessage) string {
+	var sb strings.Builder
+	writeRaw(&sb, m, 0, rawRecursionLimit)
+	return sb.String()
+}
+
+// WriteRaw 将ProtoMessage按照protoc --decode_raw的格式写入w
+func WriteRaw(w io.Writer, m ProtoMessage) error {
+	_, err := io.WriteString(w, FormatRaw(m))
+	return err
+}
+
+func writeRaw(sb *strings.Builder, m ProtoMessage, depth, budget int) {
+	indent := strings.Repeat("  ", depth)
+	for _, v := range m.Values {
+		sb.WriteString(indent)
+		fmt.Fprintf(sb, "%d", v.tag)
+		switch v._type {
+		case protowire.VarintType:
+			val, _ := v.parseVariant()
+			fmt.Fprintf(sb, ": %d\n", val)
+		case protowire.Fixed32Type:
+			val, _ := v.parseI32()
+			fmt.Fprintf(sb, ": 0x%08x\n", val)
+		case protowire.Fixed64Type:
+			val, _ := v.parseI64()
+			fmt.Fprintf(sb, ": 0x%016x\n", val)
+		case protowire.BytesType:
+			val, _ := v.parseLen()
+			if len(val) > 0 && budget > 0 {
+				if sub, err := Decode(val, NotSort); err == nil && groupDepth(sub) <= budget {
+					sb.WriteString(" {\n")
+					writeRaw(sb, sub, depth+1, budget-1)
+					sb.WriteString(indent + "}\n")
+					continue
+				}
+			}
+			sb.WriteString(": \"" + cEscape(val) + "\"\n")
+		case protowire.StartGroupType:
+			val, _ := v.parseGroup()
+			sb.WriteString(" {\n")
+			writeRaw(sb, val, depth+1, budget-1)
+			sb.WriteString(indent + "}\n")
+		}
+	}
+}
+
+// groupDepth 返回message中group的最大嵌套深度，protoc解析group时同样会消耗递归深度
+func groupDepth(m ProtoMessage) int {
+	depth := 0
+	for _, v := range m.Values {
+		if v._type != protowire.StartGroupType {
+			continue
+		}
+		group, _ := v.parseGroup()
+		if d := groupDepth(group) + 1; d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// cEscape 与protobuf C++实现的CEscape一致，不可打印字符按三位八进制转义
+func cEscape(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch c {
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\'':
+			sb.WriteString(`\'`)
+		case '\\':
+			sb.WriteString(`\\`)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&sb, "\\%03o", c)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,76 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/KarKLi/protobuf-golang-codec/internal/proto3_test"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestFormatRaw(t *testing.T) {
+	bin, err := proto.Marshal(&proto3_test.Msg{
+		I_1:  -1,
+		U_3:  150,
+		F_9:  0x1234,
+		S_12: "a\"b\n你",
+		B_13: []byte{0, 1, 0xff},
+		M_14: &proto3_test.Embeeded{I_1: 1, S_3: "testing"},
+		F_15: 0x3f800000,
+	})
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	bin, err = NewBuilder().Group(20, NewBuilder().Bytes(1, nil)).EmbeddedMsg(21, ProtoMessage{}).Build().appendTo(bin)
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	m, err := Decode(bin, NotSort)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	// 与protoc --decode_raw的输出一致
+	expect := `1: 18446744073709551615
+3: 150
+9: 0x0000000000001234
+12: "a\"b\n\344\275\240"
+13: "\000\001\377"
+14 {
+  1: 1
+  3: "testing"
+}
+15: 0x3f800000
+20 {
+  1: ""
+}
+21: ""
+`
+	if result := FormatRaw(m); result != expect {
+		t.Fatalf("format result:\n%s\n!= expected:\n%s", result, expect)
+	}
+	var buf bytes.Buffer
+	if err := WriteRaw(&buf, m); err != nil || buf.String() != expect {
+		t.Fatalf("write result:\n%s\n!= expected:\n%s, err: %+v", buf.String(), expect, err)
+	}
+}
+
+func TestFormatRawRecursionLimit(t *testing.T) {
+	// 嵌套超过10层后按字符串输出
+	sub := NewBuilder().Int32(1, 1)
+	for i := 0; i < 11; i++ {
+		sub = NewBuilder().Message(1, sub)
+	}
+	bin, err := sub.Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	m, err := Decode(bin, NotSort)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	result := FormatRaw(m)
+	expectTail := "                    1: \"\\010\\001\"\n"
+	if !bytes.Contains([]byte(result), []byte(expectTail)) {
+		t.Fatalf("format result:\n%s\nmissing %q", result, expectTail)
+	}
+}
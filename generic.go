@@ -0,0 +1,93 @@
+package codec
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// mapKeyDecoders map key类型对应的keyDecoder，key只能是整数、bool或string类型
+var mapKeyDecoders = map[protoreflect.Kind]keyDecoder{
+	protoreflect.BoolKind:     BoolKeyDecoder,
+	protoreflect.Int32Kind:    Int32KeyDecoder,
+	protoreflect.Sint32Kind:   Sint32KeyDecoder,
+	protoreflect.Uint32Kind:   Uint32KeyDecoder,
+	protoreflect.Int64Kind:    Int64KeyDecoder,
+	protoreflect.Sint64Kind:   Sint64KeyDecoder,
+	protoreflect.Uint64Kind:   Uint64KeyDecoder,
+	protoreflect.Sfixed32Kind: Sfixed32KeyDecoder,
+	protoreflect.Fixed32Kind:  Fixed32KeyDecoder,
+	protoreflect.Sfixed64Kind: Sfixed64KeyDecoder,
+	protoreflect.Fixed64Kind:  Fixed64KeyDecoder,
+	protoreflect.StringKind:   StringKeyDecoder,
+}
+
+// mapValueDecoders map value类型对应的valueDecoder
+var mapValueDecoders = map[protoreflect.Kind]valueDecoder{
+	protoreflect.BoolKind:     BoolValueDecoder,
+	protoreflect.EnumKind:     EnumValueDecoder,
+	protoreflect.Int32Kind:    Int32ValueDecoder,
+	protoreflect.Sint32Kind:   Sint32ValueDecoder,
+	protoreflect.Uint32Kind:   Uint32ValueDecoder,
+	protoreflect.Int64Kind:    Int64ValueDecoder,
+	protoreflect.Sint64Kind:   Sint64ValueDecoder,
+	protoreflect.Uint64Kind:   Uint64ValueDecoder,
+	protoreflect.Sfixed32Kind: Sfixed32ValueDecoder,
+	protoreflect.Fixed32Kind:  Fixed32ValueDecoder,
+	protoreflect.FloatKind:    FloatValueDecoder,
+	protoreflect.Sfixed64Kind: Sfixed64ValueDecoder,
+	protoreflect.Fixed64Kind:  Fixed64ValueDecoder,
+	protoreflect.DoubleKind:   DoubleValueDecoder,
+	protoreflect.StringKind:   StringValueDecoder,
+	protoreflect.BytesKind:    BytesValueDecoder,
+	protoreflect.MessageKind:  MessageValueDecoder,
+}
+
+// GetRepeated 按照字段类型解析repeated字段，返回[]T，不需要再对结果做类型断言
+//
+// 解析方式与DecodeRepeated一致，T需要与DecodeRepeated返回的元素类型一致（例如enum对应int32，message和group对应ProtoMessage），
+// 否则返回ErrTypeMismatch
+func GetRepeated[T any](p ProtoMessage, tag protowire.Number, kind protoreflect.Kind) ([]T, error) {
+	v, err := p.DecodeRepeated(tag, kind)
+	if err != nil {
+		return nil, err
+	}
+	result, ok := v.([]T)
+	if !ok {
+		return nil, ErrTypeMismatch
+	}
+	return result, nil
+}
+
+// GetMap 按照key和value的类型解析map字段，返回map[K]V，不需要通过FillMapFromProtoMapElem反射构造map
+//
+// K和V需要与对应keyDecoder和valueDecoder的解析结果类型一致（例如enum对应int32，message对应ProtoMessage），
+// 否则返回ErrTypeMismatch；重复的key后出现的覆盖先出现的
+func GetMap[K comparable, V any](p ProtoMessage, tag protowire.Number, keyKind, valKind protoreflect.Kind) (map[K]V, error) {
+	keyDec, ok := mapKeyDecoders[keyKind]
+	if !ok {
+		return nil, fmt.Errorf("not support proto map key kind %v", keyKind)
+	}
+	valDec, ok := mapValueDecoders[valKind]
+	if !ok {
+		return nil, fmt.Errorf("not support proto map value kind %v", valKind)
+	}
+	elems, err := p.DecodeMap(tag, keyDec, valDec)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[K]V, len(elems))
+	for _, elem := range elems {
+		key, ok := elem.Key.val.(K)
+		if !ok {
+			return nil, ErrTypeMismatch
+		}
+		val, ok := elem.Value.val.(V)
+		if !ok {
+			return nil, ErrTypeMismatch
+		}
+		m[key] = val
+	}
+	return m, nil
+}
@@ -0,0 +1,126 @@
+package codec
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestGetRepeated(t *testing.T) {
+	bin, err := proto.Marshal(testPackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	m, err := Decode(bin, Asc)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	i1, err := GetRepeated[int32](m, 1, protoreflect.Int32Kind)
+	if err != nil || !reflect.DeepEqual(i1, testPackedRepeatedMsg.I_1) {
+		t.Fatalf("parse result %v != real val %v, err: %+v", i1, testPackedRepeatedMsg.I_1, err)
+	}
+	s6, err := GetRepeated[int64](m, 6, protoreflect.Sint64Kind)
+	if err != nil || !reflect.DeepEqual(s6, testPackedRepeatedMsg.S_6) {
+		t.Fatalf("parse result %v != real val %v, err: %+v", s6, testPackedRepeatedMsg.S_6, err)
+	}
+	e8, err := GetRepeated[int32](m, 8, protoreflect.EnumKind)
+	if err != nil || len(e8) != len(testPackedRepeatedMsg.E_8) {
+		t.Fatalf("parse result %v != real val %v, err: %+v", e8, testPackedRepeatedMsg.E_8, err)
+	}
+	for i := range e8 {
+		if e8[i] != int32(testPackedRepeatedMsg.E_8[i]) {
+			t.Fatalf("parse result %v != real val %v", e8, testPackedRepeatedMsg.E_8)
+		}
+	}
+	f14, err := GetRepeated[float32](m, 14, protoreflect.FloatKind)
+	if err != nil || !reflect.DeepEqual(f14, testPackedRepeatedMsg.F_14) {
+		t.Fatalf("parse result %v != real val %v, err: %+v", f14, testPackedRepeatedMsg.F_14, err)
+	}
+	if _, err := GetRepeated[uint32](m, 1, protoreflect.Int32Kind); err != ErrTypeMismatch {
+		t.Fatalf("get int32 field as []uint32 should fail, err: %+v", err)
+	}
+
+	bin, err = proto.Marshal(testUnpackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	m, err = Decode(bin, NotSort)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	s15, err := GetRepeated[string](m, 15, protoreflect.StringKind)
+	if err != nil || !reflect.DeepEqual(s15, testUnpackedRepeatedMsg.S_15) {
+		t.Fatalf("parse result %v != real val %v, err: %+v", s15, testUnpackedRepeatedMsg.S_15, err)
+	}
+	m17, err := GetRepeated[ProtoMessage](m, 17, protoreflect.MessageKind)
+	if err != nil {
+		t.Fatalf("can not parse tag 17, err: %+v", err)
+	}
+	for i, msg := range testUnpackedRepeatedMsg.M_17 {
+		checkEmbeededMsgEqual(t, m17[i], msg)
+	}
+}
+
+func TestGetMap(t *testing.T) {
+	bin, err := proto.Marshal(testUnpackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	m, err := Decode(bin, Asc)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	m18, err := GetMap[int32, string](m, 18, protoreflect.Int32Kind, protoreflect.StringKind)
+	if err != nil || !reflect.DeepEqual(m18, testUnpackedRepeatedMsg.M_18) {
+		t.Fatalf("parse result %v != real val %v, err: %+v", m18, testUnpackedRepeatedMsg.M_18, err)
+	}
+	m19, err := GetMap[string, int32](m, 19, protoreflect.StringKind, protoreflect.Int32Kind)
+	if err != nil || !reflect.DeepEqual(m19, testUnpackedRepeatedMsg.M_19) {
+		t.Fatalf("parse result %v != real val %v, err: %+v", m19, testUnpackedRepeatedMsg.M_19, err)
+	}
+	m20, err := GetMap[string, ProtoMessage](m, 20, protoreflect.StringKind, protoreflect.MessageKind)
+	if err != nil || len(m20) != len(testUnpackedRepeatedMsg.M_20) {
+		t.Fatalf("parse result %v != real val %v, err: %+v", m20, testUnpackedRepeatedMsg.M_20, err)
+	}
+	for k, v := range m20 {
+		checkEmbeededMsgEqual(t, v, testUnpackedRepeatedMsg.M_20[k])
+	}
+	if _, err := GetMap[int32, int32](m, 18, protoreflect.Int32Kind, protoreflect.StringKind); err != ErrTypeMismatch {
+		t.Fatalf("get map<int32,string> as map[int32]int32 should fail, err: %+v", err)
+	}
+	if _, err := GetMap[float32, string](m, 18, protoreflect.FloatKind, protoreflect.StringKind); err == nil {
+		t.Fatalf("float map key should not be supported")
+	}
+}
+
+func TestGetMapFixedAndFloat(t *testing.T) {
+	fixed := map[uint32]float32{1: 1.5, 2: -2.25}
+	sfixed := map[int32]float64{-1: 3.5, 4: 0}
+	enum := map[int64]int32{7: 2}
+	bin, err := NewBuilder().
+		Map(1, fixed, Fixed32KeyEncoder, FloatValueEncoder).
+		Map(2, sfixed, Sfixed32KeyEncoder, DoubleValueEncoder).
+		Map(3, enum, Int64KeyEncoder, EnumValueEncoder).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	m, err := Decode(bin, NotSort)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	m1, err := GetMap[uint32, float32](m, 1, protoreflect.Fixed32Kind, protoreflect.FloatKind)
+	if err != nil || !reflect.DeepEqual(m1, fixed) {
+		t.Fatalf("parse result %v != real val %v, err: %+v", m1, fixed, err)
+	}
+	m2, err := GetMap[int32, float64](m, 2, protoreflect.Sfixed32Kind, protoreflect.DoubleKind)
+	if err != nil || !reflect.DeepEqual(m2, sfixed) {
+		t.Fatalf("parse result %v != real val %v, err: %+v", m2, sfixed, err)
+	}
+	m3, err := GetMap[int64, int32](m, 3, protoreflect.Int64Kind, protoreflect.EnumKind)
+	if err != nil || !reflect.DeepEqual(m3, enum) {
+		t.Fatalf("parse result %v != real val %v, err: %+v", m3, enum, err)
+	}
+}
@@ -0,0 +1,452 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// grpcHeaderLen gRPC消息前缀的长度：1字节压缩标志+4字节大端序长度
+const grpcHeaderLen = 5
+
+const (
+	// grpcFlagCompressed 消息数据已按照grpc-encoding压缩
+	grpcFlagCompressed = 0x01
+	// grpcFlagTrailer gRPC-Web的trailer帧，数据为HTTP/1格式的头部
+	grpcFlagTrailer = 0x80
+)
+
+// DefaultMaxGRPCMessageSize GRPCOptions.MaxMessageSize为0时单条消息（解压后）的最大字节数，与gRPC默认的接收限制一致
+const DefaultMaxGRPCMessageSize = 4 << 20
+
+var (
+	ErrUnknownEncoding  = errors.New("unknown grpc-encoding")
+	ErrInvalidFrameFlag = errors.New("invalid grpc frame flag")
+	ErrInvalidTrailer   = errors.New("invalid grpc-web trailer")
+)
+
+// Compressor 创建按照某种grpc-encoding压缩数据的io.WriteCloser，Close时写入剩余数据
+type Compressor func(w io.Writer) (io.WriteCloser, error)
+
+// Decompressor 创建按照某种grpc-encoding解压数据的io.Reader
+type Decompressor func(r io.Reader) (io.Reader, error)
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]Compressor{
+		"gzip": func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+	}
+	decompressors = map[string]Decompressor{
+		"gzip": func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+	}
+)
+
+// RegisterCompressor 注册grpc-encoding对应的压缩和解压方法，已存在时覆盖，内置gzip
+func RegisterCompressor(name string, c Compressor, d Decompressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[name] = c
+	decompressors[name] = d
+}
+
+func getCompressor(name string) (Compressor, error) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	c, ok := compressors[name]
+	if !ok || c == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEncoding, name)
+	}
+	return c, nil
+}
+
+func getDecompressor(name string) (Decompressor, error) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	d, ok := decompressors[name]
+	if !ok || d == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEncoding, name)
+	}
+	return d, nil
+}
+
+// GRPCOptions GRPCReader和GRPCWriter的选项
+type GRPCOptions struct {
+	// Encoding grpc-encoding头部的值，为空或identity表示不压缩
+	Encoding string
+	// MaxMessageSize 单条消息压缩前和解压后的最大字节数，0表示使用DefaultMaxGRPCMessageSize，负数表示不限制
+	MaxMessageSize int
+	// SortType 解析消息时使用的排序方式
+	SortType MessageSortType
+	// Web 按照gRPC-Web格式读写，允许flag为0x80的trailer帧
+	Web bool
+	// Text 按照application/grpc-web-text格式读写，即base64编码的gRPC-Web body，设置时忽略Web
+	Text bool
+}
+
+func (o GRPCOptions) web() bool {
+	return o.Web || o.Text
+}
+
+func (o GRPCOptions) maxMessageSize() int {
+	if o.MaxMessageSize == 0 {
+		return DefaultMaxGRPCMessageSize
+	}
+	return o.MaxMessageSize
+}
+
+func (o GRPCOptions) identity() bool {
+	return o.Encoding == "" || o.Encoding == "identity"
+}
+
+// GRPCFrame gRPC body中的一帧
+type GRPCFrame struct {
+	// Flag 帧前缀的第一个字节
+	Flag byte
+	// Offset 帧前缀在body中的偏移量，grpc-web-text为base64解码后数据中的偏移量
+	Offset int
+	// Data 帧数据，已压缩的数据已经解压
+	Data []byte
+	// Trailer trailer帧解析得到的头部，key为小写
+	Trailer map[string][]string
+}
+
+// Compressed 判断帧数据在传输时是否经过压缩
+func (f GRPCFrame) Compressed() bool {
+	return f.Flag&grpcFlagCompressed != 0
+}
+
+// IsTrailer 判断是否为gRPC-Web的trailer帧
+func (f GRPCFrame) IsTrailer() bool {
+	return f.Flag&grpcFlagTrailer != 0
+}
+
+// GRPCReader 将gRPC（或gRPC-Web）请求或响应的body拆分为多条消息
+//
+// gRPC-Web的trailer帧不作为消息返回，读取结束后通过Trailer获取
+//
+// 典型用法：
+//
+//	r := NewGRPCReader(body, GRPCOptions{Encoding: req.Header.Get("grpc-encoding")})
+//	for r.Next() {
+//		msg := r.Message()
+//	}
+//	if err := r.Err(); err != nil {
+//	}
+type GRPCReader struct {
+	r       *bufio.Reader
+	opts    GRPCOptions
+	pos     int
+	frame   GRPCFrame
+	msg     ProtoMessage
+	trailer map[string][]string
+	err     error
+}
+
+// NewGRPCReader 创建从r中读取gRPC消息的GRPCReader，opts.Text为true时r为base64编码的数据
+func NewGRPCReader(r io.Reader, opts GRPCOptions) *GRPCReader {
+	if opts.Text {
+		r = newBase64QuantumReader(r)
+	}
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &GRPCReader{r: br, opts: opts, trailer: map[string][]string{}}
+}
+
+// Next 读取并解析下一条消息，数据结束或出错时返回false，通过Err获取错误
+func (r *GRPCReader) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	frame, err := r.readFrame()
+	for err == nil && frame.IsTrailer() {
+		for k, vals := range frame.Trailer {
+			r.trailer[k] = append(r.trailer[k], vals...)
+		}
+		frame, err = r.readFrame()
+	}
+	if err != nil {
+		if err != io.EOF {
+			err = fmt.Errorf("read grpc frame at offset %d failed: %w", frame.Offset, err)
+		}
+		r.err = err
+		return false
+	}
+	msg, err := Decode(frame.Data, r.opts.SortType)
+	if err != nil {
+		r.err = fmt.Errorf("decode grpc message at offset %d failed: %w", frame.Offset, err)
+		return false
+	}
+	r.frame, r.msg = frame, msg
+	return true
+}
+
+// Frame 返回当前消息所在的帧
+func (r *GRPCReader) Frame() GRPCFrame {
+	return r.frame
+}
+
+// Message 返回当前消息解析得到的ProtoMessage
+func (r *GRPCReader) Message() ProtoMessage {
+	return r.msg
+}
+
+// Trailer 返回已读取的gRPC-Web trailer帧中的头部，key统一转换为小写，例如grpc-status
+func (r *GRPCReader) Trailer() map[string][]string {
+	return r.trailer
+}
+
+// Err 返回读取过程中遇到的错误，正常结束时返回nil
+func (r *GRPCReader) Err() error {
+	if r.err == io.EOF {
+		return nil
+	}
+	return r.err
+}
+
+// readFrame 读取一帧并解压数据，数据为空时返回io.EOF
+func (r *GRPCReader) readFrame() (GRPCFrame, error) {
+	frame := GRPCFrame{Offset: r.pos}
+	var header [grpcHeaderLen]byte
+	n, err := io.ReadFull(r.r, header[:])
+	r.pos += n
+	if err != nil {
+		return frame, err
+	}
+	frame.Flag = header[0]
+	validFlags := byte(grpcFlagCompressed)
+	if r.opts.web() {
+		validFlags |= grpcFlagTrailer
+	}
+	if frame.Flag&^validFlags != 0 {
+		return frame, fmt.Errorf("%w: 0x%02x", ErrInvalidFrameFlag, frame.Flag)
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	max := r.opts.maxMessageSize()
+	if max >= 0 && uint64(size) > uint64(max) {
+		return frame, fmt.Errorf("%w: %d > %d", ErrRecordTooLarge, size, max)
+	}
+	// 按实际读取到的数据分配内存，避免错误的长度导致过大的内存分配
+	var buf bytes.Buffer
+	m, err := io.CopyN(&buf, r.r, int64(size))
+	r.pos += int(m)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return frame, err
+	}
+	frame.Data = buf.Bytes()
+	if frame.Compressed() {
+		if r.opts.identity() {
+			return frame, fmt.Errorf("%w: compressed flag set with identity encoding", ErrInvalidFrameFlag)
+		}
+		if frame.Data, err = decompress(r.opts.Encoding, frame.Data, max); err != nil {
+			return frame, err
+		}
+	}
+	if frame.IsTrailer() {
+		frame.Trailer, err = parseTrailer(frame.Data)
+	}
+	return frame, err
+}
+
+// parseTrailer 解析trailer帧中HTTP/1格式的头部，每行为key: value，以\r\n分隔
+func parseTrailer(b []byte) (map[string][]string, error) {
+	h := map[string][]string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTrailer, line)
+		}
+		// gRPC-Web的trailer key为小写，直接赋值以保持与metadata一致
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		h[key] = append(h[key], strings.TrimSpace(line[i+1:]))
+	}
+	return h, nil
+}
+
+// decompress 解压数据，解压后的数据超过max字节时返回ErrRecordTooLarge
+func decompress(encoding string, b []byte, max int) ([]byte, error) {
+	d, err := getDecompressor(encoding)
+	if err != nil {
+		return nil, err
+	}
+	dr, err := d(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if max >= 0 {
+		dr = io.LimitReader(dr, int64(max)+1)
+	}
+	out, err := io.ReadAll(dr)
+	if err != nil {
+		return nil, err
+	}
+	if max >= 0 && len(out) > max {
+		return nil, fmt.Errorf("%w: decompressed size > %d", ErrRecordTooLarge, max)
+	}
+	return out, nil
+}
+
+// GRPCWriter 写入gRPC消息帧，与GRPCReader对应
+type GRPCWriter struct {
+	w    io.Writer
+	opts GRPCOptions
+}
+
+// NewGRPCWriter 创建向w写入gRPC消息帧的GRPCWriter，Encoding不为空或identity时压缩所有消息
+//
+// opts.Text为true时每一帧单独进行base64编码，与gRPC-Web服务端分块输出的方式一致
+func NewGRPCWriter(w io.Writer, opts GRPCOptions) *GRPCWriter {
+	return &GRPCWriter{w: w, opts: opts}
+}
+
+// WriteMessage 编码m并写入一帧
+func (w *GRPCWriter) WriteMessage(m ProtoMessage) error {
+	b, err := Encode(m)
+	if err != nil {
+		return err
+	}
+	return w.WriteFrame(b)
+}
+
+// WriteFrame 写入已编码的消息数据
+func (w *GRPCWriter) WriteFrame(b []byte) error {
+	max := w.opts.maxMessageSize()
+	if max >= 0 && len(b) > max {
+		return fmt.Errorf("%w: %d > %d", ErrRecordTooLarge, len(b), max)
+	}
+	var flag byte
+	if !w.opts.identity() {
+		c, err := getCompressor(w.opts.Encoding)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		cw, err := c(&buf)
+		if err != nil {
+			return err
+		}
+		if _, err := cw.Write(b); err != nil {
+			return err
+		}
+		if err := cw.Close(); err != nil {
+			return err
+		}
+		b, flag = buf.Bytes(), grpcFlagCompressed
+	}
+	return w.write(flag, b)
+}
+
+// WriteTrailer 写入gRPC-Web的trailer帧，key按照字典序输出并转换为小写，trailer帧不压缩
+func (w *GRPCWriter) WriteTrailer(h map[string][]string) error {
+	if !w.opts.web() {
+		return fmt.Errorf("%w: trailer frame requires grpc-web", ErrInvalidFrameFlag)
+	}
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		for _, v := range h[k] {
+			sb.WriteString(strings.ToLower(k) + ": " + v + "\r\n")
+		}
+	}
+	return w.write(grpcFlagTrailer, []byte(sb.String()))
+}
+
+func (w *GRPCWriter) write(flag byte, b []byte) error {
+	frame := appendGRPCFrame(make([]byte, 0, grpcHeaderLen+len(b)), flag, b)
+	if w.opts.Text {
+		frame = []byte(base64.StdEncoding.EncodeToString(frame))
+	}
+	_, err := w.w.Write(frame)
+	return err
+}
+
+// EncodeGRPCFrame 编码m并返回不压缩的gRPC消息帧
+func EncodeGRPCFrame(m ProtoMessage) ([]byte, error) {
+	b, err := Encode(m)
+	if err != nil {
+		return nil, err
+	}
+	return appendGRPCFrame(make([]byte, 0, grpcHeaderLen+len(b)), 0, b), nil
+}
+
+func appendGRPCFrame(b []byte, flag byte, data []byte) []byte {
+	b = append(b, flag)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+// base64QuantumReader 解码base64数据，每4个字符单独解码，允许数据中间出现padding
+type base64QuantumReader struct {
+	r io.Reader
+	// quantum 未凑满4个字符的数据
+	quantum []byte
+	// out 已解码未读取的数据
+	out []byte
+	buf [512]byte
+	err error
+}
+
+// newBase64QuantumReader 返回解码base64数据的io.Reader，用于application/grpc-web-text（GRPCOptions.Text）
+//
+// gRPC-Web服务端可能对每一帧单独进行base64编码，因此数据中间可能出现padding；空白字符被忽略
+func newBase64QuantumReader(r io.Reader) io.Reader {
+	return &base64QuantumReader{r: r}
+}
+
+func (b *base64QuantumReader) Read(p []byte) (int, error) {
+	for len(b.out) == 0 {
+		if b.err != nil {
+			if b.err == io.EOF && len(b.quantum) > 0 {
+				return 0, fmt.Errorf("%w: truncated base64 data", io.ErrUnexpectedEOF)
+			}
+			return 0, b.err
+		}
+		n, err := b.r.Read(b.buf[:])
+		b.err = err
+		for _, c := range b.buf[:n] {
+			switch c {
+			case ' ', '\t', '\r', '\n':
+				continue
+			}
+			b.quantum = append(b.quantum, c)
+			if len(b.quantum) < 4 {
+				continue
+			}
+			var dst [3]byte
+			m, err := base64.StdEncoding.Decode(dst[:], b.quantum)
+			if err != nil {
+				b.err = err
+				break
+			}
+			b.out = append(b.out, dst[:m]...)
+			b.quantum = b.quantum[:0]
+		}
+	}
+	n := copy(p, b.out)
+	b.out = b.out[n:]
+	return n, nil
+}
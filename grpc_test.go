@@ -0,0 +1,231 @@
+package codec
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGRPCReadWrite(t *testing.T) {
+	msgs := []ProtoMessage{
+		NewBuilder().Int32(1, 1).String(2, "first").Build(),
+		{},
+		NewBuilder().Int32(1, 3).Bytes(3, bytes.Repeat([]byte("a"), 100)).Build(),
+	}
+	for _, encoding := range []string{"", "identity", "gzip"} {
+		var buf bytes.Buffer
+		w := NewGRPCWriter(&buf, GRPCOptions{Encoding: encoding})
+		for _, m := range msgs {
+			if err := w.WriteMessage(m); err != nil {
+				t.Fatalf("write grpc message with encoding %q failed, err: %+v", encoding, err)
+			}
+		}
+		r := NewGRPCReader(&buf, GRPCOptions{Encoding: encoding})
+		var i int
+		for r.Next() {
+			if r.Frame().Compressed() != (encoding == "gzip") {
+				t.Fatalf("frame %d compressed %v with encoding %q", i, r.Frame().Compressed(), encoding)
+			}
+			expect, err := Encode(msgs[i])
+			if err != nil {
+				t.Fatalf("encode test message failed, err: %+v", err)
+			}
+			result, err := Encode(r.Message())
+			if err != nil {
+				t.Fatalf("encode grpc message failed, err: %+v", err)
+			}
+			if !bytes.Equal(result, expect) {
+				t.Fatalf("grpc message %d %v != expected %v", i, result, expect)
+			}
+			i++
+		}
+		if err := r.Err(); err != nil {
+			t.Fatalf("read grpc messages with encoding %q failed, err: %+v", encoding, err)
+		}
+		if i != len(msgs) {
+			t.Fatalf("grpc message count %d != %d", i, len(msgs))
+		}
+	}
+}
+
+func TestEncodeGRPCFrame(t *testing.T) {
+	b, err := EncodeGRPCFrame(NewBuilder().Uint64(1, 150).Build())
+	if err != nil {
+		t.Fatalf("encode grpc frame failed, err: %+v", err)
+	}
+	if expect := []byte{0, 0, 0, 0, 3, 0x08, 0x96, 0x01}; !bytes.Equal(b, expect) {
+		t.Fatalf("grpc frame %v != expected %v", b, expect)
+	}
+	// 第二帧的偏移量
+	r := NewGRPCReader(bytes.NewReader(append(b, b...)), GRPCOptions{})
+	var offsets []int
+	for r.Next() {
+		offsets = append(offsets, r.Frame().Offset)
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("read grpc messages failed, err: %+v", err)
+	}
+	if !reflect.DeepEqual(offsets, []int{0, 8}) {
+		t.Fatalf("frame offsets %v != %v", offsets, []int{0, 8})
+	}
+}
+
+func TestGRPCCustomCompressor(t *testing.T) {
+	RegisterCompressor("deflate",
+		func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, flate.BestSpeed)
+		},
+		func(r io.Reader) (io.Reader, error) {
+			return flate.NewReader(r), nil
+		})
+	var buf bytes.Buffer
+	if err := NewGRPCWriter(&buf, GRPCOptions{Encoding: "deflate"}).WriteMessage(NewBuilder().String(1, "deflated").Build()); err != nil {
+		t.Fatalf("write grpc message failed, err: %+v", err)
+	}
+	b := buf.Bytes()
+	r := NewGRPCReader(bytes.NewReader(b), GRPCOptions{Encoding: "deflate"})
+	if !r.Next() {
+		t.Fatalf("read grpc message failed, err: %+v", r.Err())
+	}
+	msg := r.Message()
+	v, err := msg.GetData(1)
+	if err != nil {
+		t.Fatalf("can not get tag=1's data, err: %+v", err)
+	}
+	if s, err := v.DecodeString(); err != nil || s != "deflated" {
+		t.Fatalf("parse result %s != real val %s, err: %+v", s, "deflated", err)
+	}
+
+	r = NewGRPCReader(bytes.NewReader(b), GRPCOptions{Encoding: "snappy"})
+	if r.Next() || !errors.Is(r.Err(), ErrUnknownEncoding) {
+		t.Fatalf("expect err %v, got %v", ErrUnknownEncoding, r.Err())
+	}
+	// 压缩标志与identity编码冲突
+	r = NewGRPCReader(bytes.NewReader(b), GRPCOptions{})
+	if r.Next() || !errors.Is(r.Err(), ErrInvalidFrameFlag) {
+		t.Fatalf("expect err %v, got %v", ErrInvalidFrameFlag, r.Err())
+	}
+}
+
+func TestGRPCInvalidFrame(t *testing.T) {
+	b, err := EncodeGRPCFrame(NewBuilder().Bytes(1, make([]byte, 10)).Build())
+	if err != nil {
+		t.Fatalf("encode grpc frame failed, err: %+v", err)
+	}
+	r := NewGRPCReader(bytes.NewReader(b[:len(b)-1]), GRPCOptions{})
+	if r.Next() || !errors.Is(r.Err(), io.ErrUnexpectedEOF) {
+		t.Fatalf("expect err %v, got %v", io.ErrUnexpectedEOF, r.Err())
+	}
+	r = NewGRPCReader(bytes.NewReader(b[:3]), GRPCOptions{})
+	if r.Next() || !errors.Is(r.Err(), io.ErrUnexpectedEOF) {
+		t.Fatalf("expect err %v, got %v", io.ErrUnexpectedEOF, r.Err())
+	}
+	r = NewGRPCReader(bytes.NewReader(b), GRPCOptions{MaxMessageSize: 5})
+	if r.Next() || !errors.Is(r.Err(), ErrRecordTooLarge) {
+		t.Fatalf("expect err %v, got %v", ErrRecordTooLarge, r.Err())
+	}
+	invalid := append([]byte{0x02}, b[1:]...)
+	r = NewGRPCReader(bytes.NewReader(invalid), GRPCOptions{})
+	if r.Next() || !errors.Is(r.Err(), ErrInvalidFrameFlag) {
+		t.Fatalf("expect err %v, got %v", ErrInvalidFrameFlag, r.Err())
+	}
+	// 解压后的数据超出限制
+	var buf bytes.Buffer
+	if err := NewGRPCWriter(&buf, GRPCOptions{Encoding: "gzip"}).WriteFrame(make([]byte, 1000)); err != nil {
+		t.Fatalf("write grpc frame failed, err: %+v", err)
+	}
+	r = NewGRPCReader(&buf, GRPCOptions{Encoding: "gzip", MaxMessageSize: 100})
+	if r.Next() || !errors.Is(r.Err(), ErrRecordTooLarge) {
+		t.Fatalf("expect err %v, got %v", ErrRecordTooLarge, r.Err())
+	}
+}
+
+func TestGRPCWeb(t *testing.T) {
+	for _, text := range []bool{false, true} {
+		var buf bytes.Buffer
+		w := NewGRPCWriter(&buf, GRPCOptions{Web: true, Text: text})
+		if err := w.WriteMessage(NewBuilder().Int32(1, 1).Build()); err != nil {
+			t.Fatalf("write grpc-web message failed, err: %+v", err)
+		}
+		if err := w.WriteMessage(NewBuilder().String(2, "second").Build()); err != nil {
+			t.Fatalf("write grpc-web message failed, err: %+v", err)
+		}
+		if err := w.WriteTrailer(map[string][]string{"Grpc-Status": {"0"}, "grpc-message": {"OK"}, "x-tag": {"a", "b"}}); err != nil {
+			t.Fatalf("write grpc-web trailer failed, err: %+v", err)
+		}
+		if text && bytes.Count(buf.Bytes(), []byte("=")) == 0 {
+			t.Fatalf("expect padding in the middle of grpc-web-text body %s", buf.String())
+		}
+		r := NewGRPCReader(&buf, GRPCOptions{Web: true, Text: text})
+		var count int
+		for r.Next() {
+			count++
+		}
+		if err := r.Err(); err != nil {
+			t.Fatalf("read grpc-web body failed, err: %+v", err)
+		}
+		if count != 2 {
+			t.Fatalf("grpc-web message count %d != %d", count, 2)
+		}
+		expect := map[string][]string{"grpc-status": {"0"}, "grpc-message": {"OK"}, "x-tag": {"a", "b"}}
+		if !reflect.DeepEqual(r.Trailer(), expect) {
+			t.Fatalf("grpc-web trailer %v != expected %v", r.Trailer(), expect)
+		}
+	}
+}
+
+func TestGRPCWebTrailerFrame(t *testing.T) {
+	body, err := EncodeGRPCFrame(NewBuilder().Uint64(1, 150).Build())
+	if err != nil {
+		t.Fatalf("encode grpc frame failed, err: %+v", err)
+	}
+	trailer := "grpc-status:0\r\ngrpc-message: \r\n"
+	body = append(body, 0x80, 0, 0, 0, byte(len(trailer)))
+	body = append(body, trailer...)
+	// 非gRPC-Web的body不允许trailer帧
+	r := NewGRPCReader(bytes.NewReader(body), GRPCOptions{})
+	for r.Next() {
+	}
+	if !errors.Is(r.Err(), ErrInvalidFrameFlag) {
+		t.Fatalf("expect err %v, got %v", ErrInvalidFrameFlag, r.Err())
+	}
+	r = NewGRPCReader(bytes.NewReader(body), GRPCOptions{Web: true})
+	for r.Next() {
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("read grpc-web body failed, err: %+v", err)
+	}
+	if expect := map[string][]string{"grpc-status": {"0"}, "grpc-message": {""}}; !reflect.DeepEqual(r.Trailer(), expect) {
+		t.Fatalf("grpc-web trailer %v != expected %v", r.Trailer(), expect)
+	}
+	invalid := append([]byte{0x80, 0, 0, 0, 7}, "invalid"...)
+	r = NewGRPCReader(bytes.NewReader(invalid), GRPCOptions{Web: true})
+	if r.Next() || !errors.Is(r.Err(), ErrInvalidTrailer) {
+		t.Fatalf("expect err %v, got %v", ErrInvalidTrailer, r.Err())
+	}
+	if err := NewGRPCWriter(io.Discard, GRPCOptions{}).WriteTrailer(nil); !errors.Is(err, ErrInvalidFrameFlag) {
+		t.Fatalf("expect err %v, got %v", ErrInvalidFrameFlag, err)
+	}
+}
+
+func TestBase64QuantumReader(t *testing.T) {
+	// 每一段单独编码，中间出现padding，并包含换行
+	in := "CJYB\nAA==\r\nAQI=\n"
+	out, err := io.ReadAll(newBase64QuantumReader(strings.NewReader(in)))
+	if err != nil {
+		t.Fatalf("read base64 data failed, err: %+v", err)
+	}
+	if expect := []byte{0x08, 0x96, 0x01, 0x00, 0x01, 0x02}; !bytes.Equal(out, expect) {
+		t.Fatalf("base64 result %v != expected %v", out, expect)
+	}
+	if _, err := io.ReadAll(newBase64QuantumReader(strings.NewReader("CJYBAA"))); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expect err %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+	if _, err := io.ReadAll(newBase64QuantumReader(strings.NewReader("CJ*B"))); err == nil {
+		t.Fatalf("expect error for invalid base64 data")
+	}
+}
@@ -0,0 +1,161 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/KarKLi/protobuf-golang-codec/internal/wirefmt"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// hexDumpWidth 每行输出的最大字节数
+const hexDumpWidth = 16
+
+// hexDumpColors 字段使用的ANSI颜色，按照字段路径首次出现的顺序轮流使用
+var hexDumpColors = []string{"32", "33", "34", "35", "36", "37"}
+
+// hexDumpErrColor 无法解析的字节使用的ANSI颜色
+const hexDumpErrColor = "1;31"
+
+// HexDumpOptions HexDump的输出选项
+type HexDumpOptions struct {
+	// Color 是否使用ANSI颜色区分不同字段
+	Color bool
+	// MaxDepth 展开嵌套message和group的最大深度，0表示不限制
+	MaxDepth int
+}
+
+// hexDumpLine 输出的一行，对应一个字段的tag、长度前缀或数据（超过hexDumpWidth时拆分为多行）
+type hexDumpLine struct {
+	offset int
+	data   []byte
+	path   string
+	note   string
+	bad    bool
+}
+
+// HexDump 输出带注释的十六进制数据，每个字段的tag、长度前缀和数据分别占一行，并标注字段路径（例如14.3）
+//
+// BytesType数据能够解析为message时展开其中的字段；数据损坏时，能够解析的部分照常输出，剩余字节标注为???
+func HexDump(w io.Writer, b []byte, opts HexDumpOptions) error {
+	h := &hexDumper{data: b, opts: opts}
+	m, err := decode(b, DecodeOptions{Spans: true}, 0, nil)
+	h.walk(m, "", 0)
+	if err != nil {
+		end := 0
+		if len(m.Values) > 0 {
+			end = m.Values[len(m.Values)-1].Span().End()
+		}
+		h.add(end, len(b)-end, "???", "invalid: "+errors.Unwrap(err).Error(), true)
+	}
+	return h.write(w)
+}
+
+type hexDumper struct {
+	data  []byte
+	opts  HexDumpOptions
+	lines []hexDumpLine
+}
+
+func (h *hexDumper) add(offset, n int, path, note string, bad bool) {
+	h.lines = append(h.lines, hexDumpLine{offset: offset, data: h.data[offset : offset+n], path: path, note: note, bad: bad})
+}
+
+func (h *hexDumper) expand(depth int) bool {
+	return h.opts.MaxDepth <= 0 || depth+1 < h.opts.MaxDepth
+}
+
+// walk 记录m中所有字段，prefix为m所在字段的路径，m中字段的偏移量必须相对于最外层数据
+func (h *hexDumper) walk(m ProtoMessage, prefix string, depth int) {
+	for _, v := range m.Values {
+		path := prefix + strconv.Itoa(int(v.tag))
+		span := v.Span()
+		h.add(span.Offset, span.TagLen, path, fmt.Sprintf("tag %d %s", v.tag, wirefmt.WireTypeName(v._type)), false)
+		switch v._type {
+		case protowire.VarintType:
+			val, _ := v.parseVariant()
+			h.add(span.PayloadOffset(), span.PayloadLen, path, fmt.Sprintf("varint %d", val), false)
+		case protowire.Fixed32Type:
+			val, _ := v.parseI32()
+			h.add(span.PayloadOffset(), span.PayloadLen, path, fmt.Sprintf("fixed32 0x%08x", val), false)
+		case protowire.Fixed64Type:
+			val, _ := v.parseI64()
+			h.add(span.PayloadOffset(), span.PayloadLen, path, fmt.Sprintf("fixed64 0x%016x", val), false)
+		case protowire.BytesType:
+			payload, _ := v.parseLen()
+			h.add(span.Offset+span.TagLen, span.PrefixLen, path, fmt.Sprintf("length %d", len(payload)), false)
+			if len(payload) > 0 && h.expand(depth) {
+				base, subPath := v.spanPath()
+				if sub, err := decode(payload, DecodeOptions{Spans: true}, base, subPath); err == nil {
+					h.walk(sub, path+".", depth+1)
+					continue
+				}
+			}
+			note := "bytes"
+			if wirefmt.IsPrintable(payload) {
+				note = strconv.Quote(string(payload))
+			}
+			h.add(span.PayloadOffset(), span.PayloadLen, path, note, false)
+		case protowire.StartGroupType:
+			if h.expand(depth) {
+				group, _ := v.parseGroup()
+				h.walk(group, path+".", depth+1)
+			} else {
+				h.add(span.PayloadOffset(), span.PayloadLen, path, "group", false)
+			}
+			h.add(span.PayloadOffset()+span.PayloadLen, span.EndTagLen, path, "end group", false)
+		}
+	}
+}
+
+func (h *hexDumper) write(w io.Writer) error {
+	pathWidth := 0
+	for _, line := range h.lines {
+		if len(line.path) > pathWidth {
+			pathWidth = len(line.path)
+		}
+	}
+	// 同一路径的字段使用相同颜色
+	colors := make(map[string]string)
+	var sb strings.Builder
+	for _, line := range h.lines {
+		color := hexDumpErrColor
+		if !line.bad {
+			var ok bool
+			if color, ok = colors[line.path]; !ok {
+				color = hexDumpColors[len(colors)%len(hexDumpColors)]
+				colors[line.path] = color
+			}
+		}
+		for i := 0; i == 0 || i < len(line.data); i += hexDumpWidth {
+			chunk := line.data[i:]
+			if len(chunk) > hexDumpWidth {
+				chunk = chunk[:hexDumpWidth]
+			}
+			hex := fmt.Sprintf("% x", chunk)
+			hexPad := strings.Repeat(" ", hexDumpWidth*3-1-len(hex))
+			path := line.path
+			pathPad := ""
+			// 拆分为多行时只在第一行输出注释
+			if i == 0 {
+				pathPad = strings.Repeat(" ", pathWidth-len(line.path))
+			}
+			if h.opts.Color {
+				hex = "\x1b[" + color + "m" + hex + "\x1b[0m"
+				path = "\x1b[" + color + "m" + path + "\x1b[0m"
+			}
+			hex += hexPad
+			path += pathPad
+			fmt.Fprintf(&sb, "%08x  %s  %s", line.offset+i, hex, path)
+			if i == 0 {
+				sb.WriteString("  " + line.note)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
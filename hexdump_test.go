@@ -0,0 +1,87 @@
+package codec
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHexDump(t *testing.T) {
+	bin, err := NewBuilder().
+		Uint64(1, 150).
+		String(2, "testing").
+		Message(14, NewBuilder().Int32(3, -1).Fixed32(2, 1)).
+		Group(5, NewBuilder().Fixed64(1, 2)).
+		Bytes(6, bytes.Repeat([]byte{0xff}, 17)).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	var out bytes.Buffer
+	if err := HexDump(&out, bin, HexDumpOptions{}); err != nil {
+		t.Fatalf("hexdump failed, err: %+v", err)
+	}
+	expect := `00000000  08                                               1     tag 1 varint
+00000001  96 01                                            1     varint 150
+00000003  12                                               2     tag 2 len
+00000004  07                                               2     length 7
+00000005  74 65 73 74 69 6e 67                             2     "testing"
+0000000c  72                                               14    tag 14 len
+0000000d  10                                               14    length 16
+0000000e  18                                               14.3  tag 3 varint
+0000000f  ff ff ff ff ff ff ff ff ff 01                    14.3  varint 18446744073709551615
+00000019  15                                               14.2  tag 2 i32
+0000001a  01 00 00 00                                      14.2  fixed32 0x00000001
+0000001e  2b                                               5     tag 5 sgroup
+0000001f  09                                               5.1   tag 1 i64
+00000020  02 00 00 00 00 00 00 00                          5.1   fixed64 0x0000000000000002
+00000028  2c                                               5     end group
+00000029  32                                               6     tag 6 len
+0000002a  11                                               6     length 17
+0000002b  ff ff ff ff ff ff ff ff ff ff ff ff ff ff ff ff  6     bytes
+0000003b  ff                                               6
+`
+	if out.String() != expect {
+		t.Fatalf("hexdump result:\n%s\n!= expected:\n%s", out.String(), expect)
+	}
+
+	// 限制展开深度
+	out.Reset()
+	if err := HexDump(&out, bin, HexDumpOptions{MaxDepth: 1}); err != nil {
+		t.Fatalf("hexdump failed, err: %+v", err)
+	}
+	if !strings.Contains(out.String(), "0000000e  18 ff ff ff ff ff ff ff ff ff 01 15 01 00 00 00  14  bytes\n") {
+		t.Fatalf("hexdump result:\n%s\nnot limited by depth", out.String())
+	}
+
+	out.Reset()
+	if err := HexDump(&out, bin[:3], HexDumpOptions{Color: true}); err != nil {
+		t.Fatalf("hexdump failed, err: %+v", err)
+	}
+	if !strings.Contains(out.String(), "\x1b[32m96 01\x1b[0m") {
+		t.Fatalf("hexdump result %q not colored", out.String())
+	}
+}
+
+func TestHexDumpCorrupted(t *testing.T) {
+	bin, err := NewBuilder().Uint64(1, 150).Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	// tag 3的长度超出数据范围
+	bin = append(bin, 0x1a, 0x05, 0x01)
+	if _, err := Decode(bin, NotSort); err == nil {
+		t.Fatalf("expect error for truncated data")
+	}
+	var out bytes.Buffer
+	if err := HexDump(&out, bin, HexDumpOptions{}); err != nil {
+		t.Fatalf("hexdump failed, err: %+v", err)
+	}
+	expect := `00000000  08                                               1    tag 1 varint
+00000001  96 01                                            1    varint 150
+00000003  1a 05 01                                         ???  invalid: unexpected EOF
+`
+	if out.String() != expect {
+		t.Fatalf("hexdump result:\n%s\n!= expected:\n%s", out.String(), expect)
+	}
+}
@@ -0,0 +1,33 @@
+package codec
+
+import "google.golang.org/protobuf/encoding/protowire"
+
+// BuildIndex 建立tag到字段下标的索引，之后GetData和GetRepeatedData直接通过索引查找，不需要排序也保留了原始的字段顺序
+//
+// DecodeOptions.Index为true时解析完成后自动建立索引；直接修改Values后需要重新调用BuildIndex
+func (p *ProtoMessage) BuildIndex() {
+	counts := make(map[protowire.Number]int)
+	for i := range p.Values {
+		counts[p.Values[i].tag]++
+	}
+	// 所有tag的下标共用一个底层数组
+	backing := make([]int, len(p.Values))
+	index := make(map[protowire.Number][]int, len(counts))
+	off := 0
+	for i := range p.Values {
+		tag := p.Values[i].tag
+		idxs, ok := index[tag]
+		if !ok {
+			n := counts[tag]
+			idxs = backing[off : off : off+n]
+			off += n
+		}
+		index[tag] = append(idxs, i)
+	}
+	p.index = index
+}
+
+// Indexed 返回message是否已经建立tag索引
+func (p *ProtoMessage) Indexed() bool {
+	return p.index != nil
+}
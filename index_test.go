@@ -0,0 +1,114 @@
+package codec
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDecodeIndex(t *testing.T) {
+	bin, err := proto.Marshal(testUnpackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	plain, err := DecodeWithOptions(bin, DecodeOptions{Spans: true})
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	m, err := DecodeWithOptions(bin, DecodeOptions{Index: true, Spans: true})
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	if !m.Indexed() || plain.Indexed() {
+		t.Fatalf("only message decoded with Index option should be indexed")
+	}
+	// 建立索引不改变字段顺序
+	out, err := Encode(m)
+	if err != nil || !bytes.Equal(out, bin) {
+		t.Fatalf("re-encode indexed message not equal to input, err: %+v", err)
+	}
+	for tag := protowire.Number(1); tag <= 21; tag++ {
+		want, _ := plain.GetRepeatedData(tag)
+		got, err := m.GetRepeatedData(tag)
+		if err != nil {
+			t.Fatalf("can not get tag=%d's data, err: %+v", tag, err)
+		}
+		if len(want) != len(got) || (len(want) > 0 && !reflect.DeepEqual(want, got)) {
+			t.Fatalf("tag=%d indexed result %v != %v", tag, got, want)
+		}
+		wantV, _ := plain.GetData(tag)
+		gotV, err := m.GetData(tag)
+		if err != nil || gotV.tag != wantV.tag || gotV.Span() != wantV.Span() {
+			t.Fatalf("tag=%d indexed data %+v != %+v, err: %+v", tag, gotV, wantV, err)
+		}
+	}
+
+	// 排序后的message同样按照索引返回
+	sorted, err := DecodeWithOptions(bin, DecodeOptions{SortType: Asc, Index: true})
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	if _, err := sorted.GetData(17); err != ErrDataNotSingularData {
+		t.Fatalf("get repeated tag 17 as singular data should fail, err: %+v", err)
+	}
+	idxs, _ := sorted.GetRepeatedData(17)
+	for _, i := range idxs {
+		if sorted.Values[i].tag != 17 {
+			t.Fatalf("index of tag 17 points to tag %d", sorted.Values[i].tag)
+		}
+	}
+}
+
+func TestDecodeIndexNested(t *testing.T) {
+	bin, err := NewBuilder().
+		Message(1, NewBuilder().Int32(2, 1).Int32(3, 2)).
+		Group(4, NewBuilder().Int32(5, 3).Int32(5, 4)).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	m, err := DecodeWithOptions(bin, DecodeOptions{Lazy: true, Index: true})
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	v1, _ := m.GetData(1)
+	sub, err := v1.DecodeEmbeddedMsg(NotSort)
+	if err != nil {
+		t.Fatalf("can not parse tag 1, err: %+v", err)
+	}
+	if !sub.Indexed() {
+		t.Fatalf("lazily decoded message should be indexed")
+	}
+	v4, _ := m.GetData(4)
+	group, err := v4.DecodeGroup()
+	if err != nil {
+		t.Fatalf("can not parse tag 4, err: %+v", err)
+	}
+	if idxs, _ := group.GetRepeatedData(5); !group.Indexed() || len(idxs) != 2 {
+		t.Fatalf("group should be indexed, got %v", idxs)
+	}
+
+	// 修改后重新建立索引
+	v, err := NewBuilder().Int32(6, 5).Value()
+	if err != nil {
+		t.Fatalf("build value failed, err: %+v", err)
+	}
+	if err := m.SetPath("6", v); err != nil {
+		t.Fatalf("set path 6 failed, err: %+v", err)
+	}
+	if err := m.SetPath("4.5[1]", v); err != nil {
+		t.Fatalf("set path 4.5[1] failed, err: %+v", err)
+	}
+	v6, err := m.GetData(6)
+	if err != nil || !m.Indexed() || v6.tag != 6 {
+		t.Fatalf("index not rebuilt after SetPath, got %+v, err: %+v", v6, err)
+	}
+	v4, _ = m.GetData(4)
+	group, _ = v4.DecodeGroup()
+	if idxs, _ := group.GetRepeatedData(5); len(idxs) != 2 {
+		t.Fatalf("group index not rebuilt after SetPath, got %v", idxs)
+	}
+}
@@ -0,0 +1,542 @@
+package codec
+
+import (
+	"math"
+	"sort"
+
+	"github.com/KarKLi/protobuf-golang-codec/internal/wirefmt"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// InferredMessage 根据若干ProtoMessage样本推断得到的message结构
+type InferredMessage struct {
+	// Fields 按照tag升序排列
+	Fields []*InferredField
+}
+
+// InferredField 推断得到的字段信息
+type InferredField struct {
+	Number protowire.Number
+	// Kind 最可能的字段类型
+	Kind protoreflect.Kind
+	// Alternatives 其他可能的字段类型，按可能性从高到低排列
+	Alternatives []protoreflect.Kind
+	// Repeated 至少有一个样本中该字段出现多次，或以packed形式出现
+	Repeated bool
+	// Packed repeated数字类型至少有一次以[packed=true]的形式出现
+	Packed bool
+	// Map 字段为map，Message为包含key（tag 1）和value（tag 2）的map entry结构
+	Map bool
+	// Message Kind为MessageKind/GroupKind或Map为true时的嵌套结构
+	Message *InferredMessage
+	// Confidence 推断结果的可信度，取值范围(0, 1]
+	Confidence float64
+	// Count 所有样本中该字段出现的总次数
+	Count int
+}
+
+// Field 根据tag获取推断得到的字段，不存在时返回nil
+func (m *InferredMessage) Field(tag protowire.Number) *InferredField {
+	idx := sort.Search(len(m.Fields), func(i int) bool {
+		return m.Fields[i].Number >= tag
+	})
+	if idx < len(m.Fields) && m.Fields[idx].Number == tag {
+		return m.Fields[idx]
+	}
+	return nil
+}
+
+// inferStats 同一个tag在所有样本中的统计数据
+type inferStats struct {
+	values []ProtoValue
+	// repeated 至少有一个样本中出现多次
+	repeated bool
+}
+
+// Infer 根据一个或多个解析得到的ProtoMessage样本推断message结构
+//
+// 每个字段会给出最可能的类型、其他可能的类型以及可信度，样本越多推断结果越准确
+func Infer(samples ...ProtoMessage) *InferredMessage {
+	stats := make(map[protowire.Number]*inferStats)
+	for _, sample := range samples {
+		counts := make(map[protowire.Number]int)
+		for _, v := range sample.Values {
+			s, ok := stats[v.tag]
+			if !ok {
+				s = &inferStats{}
+				stats[v.tag] = s
+			}
+			s.values = append(s.values, v)
+			counts[v.tag]++
+			if counts[v.tag] > 1 {
+				s.repeated = true
+			}
+		}
+	}
+	m := &InferredMessage{Fields: make([]*InferredField, 0, len(stats))}
+	for tag, s := range stats {
+		f := inferField(s.values)
+		f.Number = tag
+		f.Count = len(s.values)
+		f.Repeated = f.Repeated || s.repeated
+		if f.Map && !f.Repeated {
+			// 只出现一次的map entry无法与普通嵌套message区分
+			f.Map = false
+			f.Confidence *= 0.5
+		}
+		m.Fields = append(m.Fields, f)
+	}
+	sort.Slice(m.Fields, func(i, j int) bool {
+		return m.Fields[i].Number < m.Fields[j].Number
+	})
+	return m
+}
+
+func inferField(values []ProtoValue) *InferredField {
+	byType := make(map[protowire.Type][]ProtoValue)
+	for _, v := range values {
+		byType[v._type] = append(byType[v._type], v)
+	}
+	if len(byType) == 1 {
+		return inferSingleType(values[0]._type, values)
+	}
+	// packed与unpacked混合出现的repeated数字类型
+	if len(byType) == 2 && len(byType[protowire.BytesType]) > 0 {
+		for typ, scalars := range byType {
+			if typ == protowire.BytesType {
+				continue
+			}
+			if f := inferMixedPacked(typ, scalars, byType[protowire.BytesType]); f != nil {
+				return f
+			}
+		}
+	}
+	// 多种wire type无法解释时，取出现次数最多的wire type，并按比例降低可信度
+	var major protowire.Type
+	for typ, vals := range byType {
+		if len(vals) > len(byType[major]) || (len(vals) == len(byType[major]) && typ < major) {
+			major = typ
+		}
+	}
+	f := inferSingleType(major, byType[major])
+	f.Confidence *= float64(len(byType[major])) / float64(len(values))
+	return f
+}
+
+func inferSingleType(typ protowire.Type, values []ProtoValue) *InferredField {
+	switch typ {
+	case protowire.VarintType:
+		vals := make([]uint64, 0, len(values))
+		for _, v := range values {
+			val, _ := v.parseVariant()
+			vals = append(vals, val)
+		}
+		return inferVarint(vals)
+	case protowire.Fixed32Type:
+		vals := make([]uint32, 0, len(values))
+		for _, v := range values {
+			val, _ := v.parseI32()
+			vals = append(vals, val)
+		}
+		return inferFixed32(vals)
+	case protowire.Fixed64Type:
+		vals := make([]uint64, 0, len(values))
+		for _, v := range values {
+			val, _ := v.parseI64()
+			vals = append(vals, val)
+		}
+		return inferFixed64(vals)
+	case protowire.StartGroupType:
+		groups := make([]ProtoMessage, 0, len(values))
+		for _, v := range values {
+			group, _ := v.parseGroup()
+			groups = append(groups, group)
+		}
+		// group的wire type是确定的，可信度最高
+		return &InferredField{Kind: protoreflect.GroupKind, Message: Infer(groups...), Confidence: 1}
+	}
+	payloads := make([][]byte, 0, len(values))
+	for _, v := range values {
+		payload, _ := v.parseLen()
+		payloads = append(payloads, payload)
+	}
+	return inferBytes(payloads)
+}
+
+func inferVarint(vals []uint64) *InferredField {
+	allBool, hasNegative, fitInt32 := true, false, true
+	distinct := make(map[uint64]struct{})
+	for _, v := range vals {
+		distinct[v] = struct{}{}
+		if v > 1 {
+			allBool = false
+		}
+		if int64(v) < 0 {
+			hasNegative = true
+		}
+		if int64(v) < math.MinInt32 || int64(v) > math.MaxInt32 {
+			fitInt32 = false
+		}
+	}
+	switch {
+	case allBool:
+		f := &InferredField{
+			Kind:         protoreflect.BoolKind,
+			Alternatives: []protoreflect.Kind{protoreflect.EnumKind, protoreflect.Int32Kind},
+			Confidence:   0.5,
+		}
+		if len(distinct) == 2 {
+			f.Confidence = 0.8
+		}
+		return f
+	case hasNegative:
+		// 负数的int32/int64使用10字节的varint编码，sint和uint极少会出现这么大的值
+		if fitInt32 {
+			return &InferredField{
+				Kind:         protoreflect.Int32Kind,
+				Alternatives: []protoreflect.Kind{protoreflect.Int64Kind, protoreflect.Uint64Kind},
+				Confidence:   0.9,
+			}
+		}
+		return &InferredField{
+			Kind:         protoreflect.Int64Kind,
+			Alternatives: []protoreflect.Kind{protoreflect.Uint64Kind, protoreflect.Fixed64Kind},
+			Confidence:   0.9,
+		}
+	}
+	var maxVal uint64
+	for v := range distinct {
+		if v > maxVal {
+			maxVal = v
+		}
+	}
+	// 取值较小且种类较少时可能是enum
+	if len(vals) >= 3 && maxVal < 32 && len(distinct) <= 8 && len(distinct) < len(vals) {
+		return &InferredField{
+			Kind:         protoreflect.EnumKind,
+			Alternatives: []protoreflect.Kind{protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Uint32Kind},
+			Confidence:   0.4,
+		}
+	}
+	if fitInt32 {
+		return &InferredField{
+			Kind:         protoreflect.Int32Kind,
+			Alternatives: []protoreflect.Kind{protoreflect.Sint32Kind, protoreflect.Uint32Kind, protoreflect.Int64Kind, protoreflect.EnumKind},
+			Confidence:   0.5,
+		}
+	}
+	return &InferredField{
+		Kind:         protoreflect.Int64Kind,
+		Alternatives: []protoreflect.Kind{protoreflect.Sint64Kind, protoreflect.Uint64Kind},
+		Confidence:   0.5,
+	}
+}
+
+func inferFixed32(vals []uint32) *InferredField {
+	for _, v := range vals {
+		if !plausibleFloat(float64(math.Float32frombits(v)), 1e-6, 1e9) {
+			return &InferredField{
+				Kind:         protoreflect.Fixed32Kind,
+				Alternatives: []protoreflect.Kind{protoreflect.Sfixed32Kind, protoreflect.FloatKind},
+				Confidence:   0.7,
+			}
+		}
+	}
+	return &InferredField{
+		Kind:         protoreflect.FloatKind,
+		Alternatives: []protoreflect.Kind{protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind},
+		Confidence:   0.7,
+	}
+}
+
+func inferFixed64(vals []uint64) *InferredField {
+	for _, v := range vals {
+		if !plausibleFloat(math.Float64frombits(v), 1e-9, 1e15) {
+			return &InferredField{
+				Kind:         protoreflect.Fixed64Kind,
+				Alternatives: []protoreflect.Kind{protoreflect.Sfixed64Kind, protoreflect.DoubleKind},
+				Confidence:   0.7,
+			}
+		}
+	}
+	return &InferredField{
+		Kind:         protoreflect.DoubleKind,
+		Alternatives: []protoreflect.Kind{protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind},
+		Confidence:   0.7,
+	}
+}
+
+// plausibleFloat 判断浮点数是否是一个"正常"的值，整数的位模式解释为浮点数时通常非常大或非常小
+func plausibleFloat(f, min, max float64) bool {
+	if f == 0 {
+		return true
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return false
+	}
+	f = math.Abs(f)
+	return f >= min && f <= max
+}
+
+func inferBytes(payloads [][]byte) *InferredField {
+	allString, allMessage, allPacked := true, true, true
+	nonEmpty := 0
+	msgs := make([]ProtoMessage, 0, len(payloads))
+	var packedVals []uint64
+	var chunks [][]byte
+	counts := make([]int, 0, len(payloads))
+	for _, payload := range payloads {
+		if len(payload) == 0 {
+			// 空数据可以是任意类型
+			msgs = append(msgs, ProtoMessage{})
+			continue
+		}
+		nonEmpty++
+		chunks = append(chunks, payload)
+		if allString && !wirefmt.IsPrintable(payload) {
+			allString = false
+		}
+		if allMessage {
+			msg, err := Decode(payload, NotSort)
+			if err != nil {
+				allMessage = false
+			}
+			msgs = append(msgs, msg)
+		}
+		if allPacked {
+			vals, ok := consumePackedVarints(payload)
+			if !ok {
+				allPacked = false
+			}
+			packedVals = append(packedVals, vals...)
+			counts = append(counts, len(vals))
+		}
+	}
+	switch {
+	case nonEmpty == 0:
+		return &InferredField{
+			Kind:         protoreflect.StringKind,
+			Alternatives: []protoreflect.Kind{protoreflect.BytesKind, protoreflect.MessageKind},
+			Confidence:   0.2,
+		}
+	case allString:
+		f := &InferredField{Kind: protoreflect.StringKind, Confidence: 0.9}
+		if allMessage {
+			f.Alternatives = []protoreflect.Kind{protoreflect.MessageKind, protoreflect.BytesKind}
+			f.Confidence = 0.6
+		} else {
+			f.Alternatives = []protoreflect.Kind{protoreflect.BytesKind}
+		}
+		return f
+	case allMessage:
+		f := &InferredField{
+			Kind:         protoreflect.MessageKind,
+			Alternatives: []protoreflect.Kind{protoreflect.BytesKind},
+			Message:      Infer(msgs...),
+			Confidence:   0.8,
+			Map:          isMapEntry(msgs),
+		}
+		return f
+	case allPacked && plausiblePackedVarints(chunks, counts, packedVals):
+		f := inferVarint(packedVals)
+		f.Alternatives = append([]protoreflect.Kind{}, f.Alternatives...)
+		f.Alternatives = append(f.Alternatives, protoreflect.BytesKind)
+		f.Repeated = true
+		f.Packed = true
+		f.Confidence *= 0.6
+		return f
+	}
+	if f := inferPackedFloat(chunks); f != nil {
+		return f
+	}
+	// 无法确定时作为bytes，能够按照packed数据解析时作为其他可能的类型
+	f := &InferredField{Kind: protoreflect.BytesKind, Confidence: 0.6}
+	if allPacked {
+		f.Alternatives = append(f.Alternatives, inferVarint(packedVals).Kind)
+	}
+	if packedWidth(chunks, 8) {
+		f.Alternatives = append(f.Alternatives, protoreflect.Fixed64Kind)
+	}
+	if packedWidth(chunks, 4) {
+		f.Alternatives = append(f.Alternatives, protoreflect.Fixed32Kind)
+	}
+	return f
+}
+
+// plausiblePackedVarints 判断按照packed varint解析的结果是否可信，counts为每段数据解析得到的元素个数
+//
+// 任意以小于0x80的字节结尾的数据都能解析为varint，哈希值、id等二进制数据需要排除：
+// 长度相同的多段数据解析得到的元素个数不同时更可能是定长的二进制数据；
+// 随机数据中约一半的字节带有continuation位，而真实的数据通常是较小的值（int32负数除外），或者编码长度基本一致
+func plausiblePackedVarints(chunks [][]byte, counts []int, vals []uint64) bool {
+	if len(vals) == 0 {
+		return false
+	}
+	sameLen, sameCount := true, true
+	for i := 1; i < len(chunks); i++ {
+		sameLen = sameLen && len(chunks[i]) == len(chunks[0])
+		sameCount = sameCount && counts[i] == counts[0]
+	}
+	if len(chunks) > 1 && sameLen && !sameCount {
+		return false
+	}
+	widths := make(map[int]int)
+	total, continuation := 0, 0
+	dominant, dominantWidth := 0, 0
+	for _, v := range vals {
+		n := protowire.SizeVarint(v)
+		widths[n]++
+		if widths[n] > dominant {
+			dominant, dominantWidth = widths[n], n
+		}
+		if int64(v) < 0 && int64(v) >= math.MinInt32 {
+			// int32负数固定编码为10字节
+			continue
+		}
+		total += n
+		continuation += n - 1
+	}
+	return continuation*8 <= total || (dominantWidth >= 2 && dominant*4 >= len(vals)*3)
+}
+
+// inferPackedFloat 长度是4或8的倍数的数据全部能够解析为"正常"的浮点数时，推断为packed float/double
+//
+// 整数无法与任意的二进制数据区分，因此只推断浮点数；随机数据也有一定概率是"正常"的浮点数，元素过少时不推断
+func inferPackedFloat(chunks [][]byte) *InferredField {
+	if packedWidth(chunks, 8) {
+		var vals []uint64
+		for _, payload := range chunks {
+			for ; len(payload) > 0; payload = payload[8:] {
+				val, _ := protowire.ConsumeFixed64(payload)
+				vals = append(vals, val)
+			}
+		}
+		if f := inferFixed64(vals); len(vals) >= 2 && f.Kind == protoreflect.DoubleKind {
+			f.Alternatives = append(f.Alternatives, protoreflect.BytesKind)
+			f.Repeated, f.Packed = true, true
+			f.Confidence *= 0.6
+			return f
+		}
+	}
+	if packedWidth(chunks, 4) {
+		var vals []uint32
+		for _, payload := range chunks {
+			for ; len(payload) > 0; payload = payload[4:] {
+				val, _ := protowire.ConsumeFixed32(payload)
+				vals = append(vals, val)
+			}
+		}
+		if f := inferFixed32(vals); len(vals) >= 4 && f.Kind == protoreflect.FloatKind {
+			f.Alternatives = append(f.Alternatives, protoreflect.BytesKind)
+			f.Repeated, f.Packed = true, true
+			f.Confidence *= 0.6
+			return f
+		}
+	}
+	return nil
+}
+
+// packedWidth 判断所有数据的长度是否都是width的倍数
+func packedWidth(chunks [][]byte, width int) bool {
+	for _, payload := range chunks {
+		if len(payload)%width != 0 {
+			return false
+		}
+	}
+	return len(chunks) > 0
+}
+
+// inferMixedPacked 处理同一个字段既有packed数据又有单个数字元素的情况
+func inferMixedPacked(typ protowire.Type, scalars []ProtoValue, chunks []ProtoValue) *InferredField {
+	switch typ {
+	case protowire.VarintType:
+		var vals []uint64
+		for _, v := range scalars {
+			val, _ := v.parseVariant()
+			vals = append(vals, val)
+		}
+		for _, v := range chunks {
+			payload, _ := v.parseLen()
+			elems, ok := consumePackedVarints(payload)
+			if !ok {
+				return nil
+			}
+			vals = append(vals, elems...)
+		}
+		f := inferVarint(vals)
+		f.Repeated, f.Packed = true, true
+		return f
+	case protowire.Fixed32Type:
+		var vals []uint32
+		for _, v := range scalars {
+			val, _ := v.parseI32()
+			vals = append(vals, val)
+		}
+		for _, v := range chunks {
+			payload, _ := v.parseLen()
+			if len(payload)%4 != 0 {
+				return nil
+			}
+			for ; len(payload) > 0; payload = payload[4:] {
+				val, _ := protowire.ConsumeFixed32(payload)
+				vals = append(vals, val)
+			}
+		}
+		f := inferFixed32(vals)
+		f.Repeated, f.Packed = true, true
+		return f
+	case protowire.Fixed64Type:
+		var vals []uint64
+		for _, v := range scalars {
+			val, _ := v.parseI64()
+			vals = append(vals, val)
+		}
+		for _, v := range chunks {
+			payload, _ := v.parseLen()
+			if len(payload)%8 != 0 {
+				return nil
+			}
+			for ; len(payload) > 0; payload = payload[8:] {
+				val, _ := protowire.ConsumeFixed64(payload)
+				vals = append(vals, val)
+			}
+		}
+		f := inferFixed64(vals)
+		f.Repeated, f.Packed = true, true
+		return f
+	}
+	return nil
+}
+
+// consumePackedVarints 按照packed varint解析数据，出现非最短编码的varint时返回false
+func consumePackedVarints(payload []byte) ([]uint64, bool) {
+	var vals []uint64
+	for len(payload) > 0 {
+		val, n := protowire.ConsumeVarint(payload)
+		if n < 0 || n != protowire.SizeVarint(val) {
+			return nil, false
+		}
+		vals = append(vals, val)
+		payload = payload[n:]
+	}
+	return vals, true
+}
+
+// isMapEntry 判断所有嵌套message是否都只包含最多一个tag 1和最多一个tag 2
+func isMapEntry(msgs []ProtoMessage) bool {
+	for _, msg := range msgs {
+		var hasKey, hasVal bool
+		for _, v := range msg.Values {
+			switch {
+			case v.tag == keyTag && !hasKey:
+				hasKey = true
+			case v.tag == valTag && !hasVal:
+				hasVal = true
+			default:
+				return false
+			}
+		}
+	}
+	return len(msgs) > 0
+}
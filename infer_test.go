@@ -0,0 +1,176 @@
+package codec
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/KarKLi/protobuf-golang-codec/internal/proto3_test"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func decodeSamples(t *testing.T, msgs ...proto.Message) []ProtoMessage {
+	samples := make([]ProtoMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		bin, err := proto.Marshal(msg)
+		if err != nil {
+			t.Fatalf("can not marshal test proto message, err: %+v", err)
+		}
+		m, err := Decode(bin, NotSort)
+		if err != nil {
+			t.Fatalf("decode test proto message failed, err: %+v", err)
+		}
+		samples = append(samples, m)
+	}
+	return samples
+}
+
+func checkInferredKind(t *testing.T, m *InferredMessage, tag protowire.Number, kind protoreflect.Kind) *InferredField {
+	f := m.Field(tag)
+	if f == nil {
+		t.Fatalf("tag %d not inferred", tag)
+	}
+	if f.Kind != kind {
+		t.Fatalf("tag %d inferred kind %v != expected %v", tag, f.Kind, kind)
+	}
+	if f.Confidence <= 0 || f.Confidence > 1 {
+		t.Fatalf("tag %d confidence %f out of range", tag, f.Confidence)
+	}
+	return f
+}
+
+func TestInferNonRepeatedData(t *testing.T) {
+	samples := decodeSamples(t,
+		&proto3_test.Msg{I_1: -5, B_7: true, D_11: 3.25, S_12: "hello", M_14: &proto3_test.Embeeded{I_1: 1, S_3: "你好"}, F_17: 1.5},
+		&proto3_test.Msg{I_1: -7, B_7: false, D_11: -100.5, S_12: "world", M_14: &proto3_test.Embeeded{I_1: 2, S_3: "abc"}, F_17: 2.5},
+	)
+	m := Infer(samples...)
+	checkInferredKind(t, m, 1, protoreflect.Int32Kind)
+	checkInferredKind(t, m, 7, protoreflect.BoolKind)
+	checkInferredKind(t, m, 11, protoreflect.DoubleKind)
+	checkInferredKind(t, m, 12, protoreflect.StringKind)
+	checkInferredKind(t, m, 17, protoreflect.FloatKind)
+	m14 := checkInferredKind(t, m, 14, protoreflect.MessageKind)
+	if m14.Repeated || m14.Map {
+		t.Fatalf("tag 14 inferred as repeated or map")
+	}
+	checkInferredKind(t, m14.Message, 3, protoreflect.StringKind)
+	if f := m.Field(7); f.Count != 1 {
+		// false是proto3的零值，不会写入二进制流
+		t.Fatalf("tag 7 count %d != 1", f.Count)
+	}
+}
+
+func TestInferRepeatedData(t *testing.T) {
+	samples := decodeSamples(t,
+		&proto3_test.RepeatedMsgWithPacked{I_1: []int32{1, -2, 3}, F_9: []uint64{1 << 60, 2 << 60}},
+		&proto3_test.RepeatedMsgWithUnpacked{
+			I_1:  []int32{4, 5},
+			S_15: []string{"a", "b"},
+			M_17: []*proto3_test.Embeeded{{I_1: 1}, {S_3: "x"}},
+			M_18: map[int32]string{1: "a", 2: "b"},
+		},
+	)
+	m := Infer(samples...)
+	i1 := checkInferredKind(t, m, 1, protoreflect.Int32Kind)
+	if !i1.Repeated || !i1.Packed {
+		t.Fatalf("tag 1 should be inferred as packed repeated field")
+	}
+	s15 := checkInferredKind(t, m, 15, protoreflect.StringKind)
+	if !s15.Repeated {
+		t.Fatalf("tag 15 should be inferred as repeated field")
+	}
+	m17 := checkInferredKind(t, m, 17, protoreflect.MessageKind)
+	if !m17.Repeated || m17.Map {
+		t.Fatalf("tag 17 should be inferred as repeated message")
+	}
+	m18 := checkInferredKind(t, m, 18, protoreflect.MessageKind)
+	if !m18.Map {
+		t.Fatalf("tag 18 should be inferred as map")
+	}
+	checkInferredKind(t, m18.Message, 1, protoreflect.Int32Kind)
+	checkInferredKind(t, m18.Message, 2, protoreflect.StringKind)
+}
+
+func TestInferGroup(t *testing.T) {
+	bin, err := NewBuilder().Group(2, NewBuilder().Fixed32(3, 0xdeadbeef)).Marshal()
+	if err != nil {
+		t.Fatalf("can not build group message, err: %+v", err)
+	}
+	sample, err := Decode(bin, NotSort)
+	if err != nil {
+		t.Fatalf("decode group message failed, err: %+v", err)
+	}
+	m := Infer(sample)
+	g := checkInferredKind(t, m, 2, protoreflect.GroupKind)
+	checkInferredKind(t, g.Message, 3, protoreflect.Fixed32Kind)
+}
+
+func TestInferPacked(t *testing.T) {
+	samples := decodeSamples(t,
+		&proto3_test.RepeatedMsgWithPacked{I_1: []int32{1, 2, 3, 300, 5, 6, 7, 8}, D_11: []float64{1.5, -2.25}, F_14: []float32{0.5, 3, 8}},
+		&proto3_test.RepeatedMsgWithPacked{I_1: []int32{4, -5, 6}, D_11: []float64{100}, F_14: []float32{-7.5, 1.25}},
+	)
+	m := Infer(samples...)
+	i1 := checkInferredKind(t, m, 1, protoreflect.Int32Kind)
+	if !i1.Repeated || !i1.Packed {
+		t.Fatalf("tag 1 should be inferred as packed repeated field")
+	}
+	d11 := checkInferredKind(t, m, 11, protoreflect.DoubleKind)
+	if !d11.Repeated || !d11.Packed {
+		t.Fatalf("tag 11 should be inferred as packed repeated field")
+	}
+	f14 := checkInferredKind(t, m, 14, protoreflect.FloatKind)
+	if !f14.Repeated || !f14.Packed {
+		t.Fatalf("tag 14 should be inferred as packed repeated field")
+	}
+}
+
+func TestInferRandomBytes(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	blob := func(n int) []byte {
+		b := make([]byte, n)
+		r.Read(b)
+		return b
+	}
+	// 单个样本中的随机数据
+	for i := 0; i < 500; i++ {
+		bin, err := NewBuilder().Bytes(1, blob(8+r.Intn(57))).Marshal()
+		if err != nil {
+			t.Fatalf("can not build test proto message, err: %+v", err)
+		}
+		sample, err := Decode(bin, NotSort)
+		if err != nil {
+			t.Fatalf("decode test proto message failed, err: %+v", err)
+		}
+		if f := Infer(sample).Field(1); f.Packed {
+			t.Fatalf("random bytes %x inferred as packed %v", sample.Values[0].val, f.Kind)
+		}
+	}
+	// 多个样本中定长的哈希值
+	for i := 0; i < 100; i++ {
+		samples := make([]ProtoMessage, 0, 4)
+		for j := 0; j < 4; j++ {
+			bin, err := NewBuilder().Bytes(1, blob(32)).Marshal()
+			if err != nil {
+				t.Fatalf("can not build test proto message, err: %+v", err)
+			}
+			sample, err := Decode(bin, NotSort)
+			if err != nil {
+				t.Fatalf("decode test proto message failed, err: %+v", err)
+			}
+			samples = append(samples, sample)
+		}
+		if f := Infer(samples...).Field(1); f.Packed {
+			t.Fatalf("random hashes inferred as packed %v", f.Kind)
+		}
+	}
+	// 非最短编码的varint
+	bin, err := NewBuilder().Bytes(1, []byte{0x81, 0x00, 0x02}).Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	sample, _ := Decode(bin, NotSort)
+	checkInferredKind(t, Infer(sample), 1, protoreflect.BytesKind)
+}
@@ -0,0 +1,42 @@
+// Package wirefmt 提供codec包和protodump共用的wire格式输出辅助函数
+package wirefmt
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// IsPrintable 判断数据是否是合法的UTF-8可打印字符串，包括空白字符
+func IsPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// WireTypeName 返回wire type的简短名称
+func WireTypeName(typ protowire.Type) string {
+	switch typ {
+	case protowire.VarintType:
+		return "varint"
+	case protowire.Fixed32Type:
+		return "i32"
+	case protowire.Fixed64Type:
+		return "i64"
+	case protowire.BytesType:
+		return "len"
+	case protowire.StartGroupType:
+		return "sgroup"
+	case protowire.EndGroupType:
+		return "egroup"
+	}
+	return fmt.Sprintf("type%d", typ)
+}
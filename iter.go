@@ -0,0 +1,163 @@
+package codec
+
+import (
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// 本文件的迭代器都是func(yield func(K, V) bool)类型，与iter.Seq2[K, V]兼容，
+// Go 1.23起可以直接用于for range，更早的版本可以传入yield函数调用
+
+// All 按照Values中的顺序遍历所有字段，返回tag和字段
+func (p ProtoMessage) All() func(yield func(protowire.Number, ProtoValue) bool) {
+	return func(yield func(protowire.Number, ProtoValue) bool) {
+		for i := range p.Values {
+			if !yield(p.Values[i].tag, p.Values[i]) {
+				return
+			}
+		}
+	}
+}
+
+// Field 按照出现顺序遍历tag的所有字段，返回字段在Values中的下标和字段
+//
+// 与GetRepeatedData的查找方式一致（索引、二分查找或者遍历），但不需要分配下标数组
+func (p ProtoMessage) Field(tag protowire.Number) func(yield func(int, ProtoValue) bool) {
+	return func(yield func(int, ProtoValue) bool) {
+		if p.index != nil {
+			for _, i := range p.index[tag] {
+				if !yield(i, p.Values[i]) {
+					return
+				}
+			}
+			return
+		}
+		if p.sortType != NotSort {
+			for i := p.search(tag); i < len(p.Values) && p.Values[i].tag == tag; i++ {
+				if !yield(i, p.Values[i]) {
+					return
+				}
+			}
+			return
+		}
+		for i := range p.Values {
+			if p.Values[i].tag == tag && !yield(i, p.Values[i]) {
+				return
+			}
+		}
+	}
+}
+
+// PackedElements 按照出现顺序逐个解码repeated数字类型字段的元素，不会像PackedRepeatedXXXDecoder一样生成整个数组
+//
+// 与DecodeRepeated一致，同时接受多段packed数据和未packed的元素。T需要与对应PackedRepeatedXXXDecoder返回的元素类型一致
+// （例如enum对应int32），否则产生ErrTypeMismatch。解析失败时产生零值和错误，之后停止遍历
+func PackedElements[T any](p ProtoMessage, tag protowire.Number, kind protoreflect.Kind) func(yield func(T, error) bool) {
+	return func(yield func(T, error) bool) {
+		var zero T
+		if _, ok := packedDecoders[kind]; !ok {
+			yield(zero, fmt.Errorf("not support proto kind %v", kind))
+			return
+		}
+		wireType := kindWireType(kind)
+		// next 将原始数据转换为元素并交给yield，返回是否继续遍历
+		next := func(raw uint64) bool {
+			var elem T
+			if !packedElem(&elem, kind, raw) {
+				yield(zero, ErrTypeMismatch)
+				return false
+			}
+			return yield(elem, nil)
+		}
+		p.Field(tag)(func(_ int, v ProtoValue) bool {
+			var raw uint64
+			var err error
+			switch v._type {
+			case protowire.BytesType:
+				var payload []byte
+				payload, err = v.parseLen()
+				if err != nil {
+					yield(zero, err)
+					return false
+				}
+				for len(payload) > 0 {
+					val, n := consumePackedElem(payload, wireType)
+					if n < 0 {
+						yield(zero, protowire.ParseError(n))
+						return false
+					}
+					payload = payload[n:]
+					if !next(val) {
+						return false
+					}
+				}
+				return true
+			case wireType:
+				switch wireType {
+				case protowire.VarintType:
+					raw, err = v.parseVariant()
+				case protowire.Fixed32Type:
+					var val uint32
+					val, err = v.parseI32()
+					raw = uint64(val)
+				case protowire.Fixed64Type:
+					raw, err = v.parseI64()
+				}
+			default:
+				err = ErrTypeMismatch
+			}
+			if err != nil {
+				yield(zero, err)
+				return false
+			}
+			return next(raw)
+		})
+	}
+}
+
+// consumePackedElem 从packed数据的开头解析一个元素的原始数据，返回原始数据和消耗的字节数
+func consumePackedElem(b []byte, typ protowire.Type) (uint64, int) {
+	switch typ {
+	case protowire.Fixed32Type:
+		v, n := protowire.ConsumeFixed32(b)
+		return uint64(v), n
+	case protowire.Fixed64Type:
+		return protowire.ConsumeFixed64(b)
+	}
+	return protowire.ConsumeVarint(b)
+}
+
+// packedElem 按照字段类型将原始数据转换为元素写入elem，elem的类型与字段类型不一致时返回false
+func packedElem(elem interface{}, kind protoreflect.Kind, raw uint64) bool {
+	ok := false
+	switch e := elem.(type) {
+	case *bool:
+		*e, ok = protowire.DecodeBool(raw), kind == protoreflect.BoolKind
+	case *int32:
+		switch kind {
+		case protoreflect.EnumKind, protoreflect.Int32Kind, protoreflect.Sfixed32Kind:
+			*e, ok = int32(raw), true
+		case protoreflect.Sint32Kind:
+			*e, ok = int32(protowire.DecodeZigZag(raw)), true
+		}
+	case *uint32:
+		*e, ok = uint32(raw), kind == protoreflect.Uint32Kind || kind == protoreflect.Fixed32Kind
+	case *int64:
+		switch kind {
+		case protoreflect.Int64Kind, protoreflect.Sfixed64Kind:
+			*e, ok = int64(raw), true
+		case protoreflect.Sint64Kind:
+			*e, ok = protowire.DecodeZigZag(raw), true
+		}
+	case *uint64:
+		*e, ok = raw, kind == protoreflect.Uint64Kind || kind == protoreflect.Fixed64Kind
+	case *float32:
+		*e, ok = math.Float32frombits(uint32(raw)), kind == protoreflect.FloatKind
+	case *float64:
+		*e, ok = math.Float64frombits(raw), kind == protoreflect.DoubleKind
+	}
+	return ok
+}
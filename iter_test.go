@@ -0,0 +1,146 @@
+//go:build go1.23
+
+package codec
+
+import (
+	"iter"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestIterAll(t *testing.T) {
+	bin, err := proto.Marshal(testUnpackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	m, err := DecodeWithOptions(bin, DecodeOptions{Spans: true})
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	var all iter.Seq2[protowire.Number, ProtoValue] = m.All()
+	i := 0
+	for tag, v := range all {
+		if tag != m.Values[i].tag || v.Span() != m.Values[i].Span() {
+			t.Fatalf("field %d iterate result tag=%d != real tag=%d", i, tag, m.Values[i].tag)
+		}
+		i++
+	}
+	if i != len(m.Values) {
+		t.Fatalf("iterate %d fields != real %d fields", i, len(m.Values))
+	}
+	i = 0
+	for range m.All() {
+		if i++; i == 3 {
+			break
+		}
+	}
+}
+
+func TestIterField(t *testing.T) {
+	bin, err := proto.Marshal(testUnpackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	for _, opts := range []DecodeOptions{{SortType: NotSort}, {SortType: Asc}, {SortType: Desc}, {Index: true}} {
+		m, err := DecodeWithOptions(bin, opts)
+		if err != nil {
+			t.Fatalf("decode test proto message failed, err: %+v", err)
+		}
+		for tag := protowire.Number(1); tag <= 21; tag++ {
+			want, _ := m.GetRepeatedData(tag)
+			got := []int{}
+			var field iter.Seq2[int, ProtoValue] = m.Field(tag)
+			for i, v := range field {
+				if v.tag != tag {
+					t.Fatalf("opts %+v iterate tag %d returns tag %d", opts, tag, v.tag)
+				}
+				got = append(got, i)
+			}
+			if len(want) != len(got) || (len(want) > 0 && !reflect.DeepEqual(want, got)) {
+				t.Fatalf("opts %+v tag=%d iterate result %v != %v", opts, tag, got, want)
+			}
+		}
+	}
+}
+
+func TestIterPackedElements(t *testing.T) {
+	packed, err := proto.Marshal(testPackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	unpacked, err := proto.Marshal(testUnpackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	bin := append(append([]byte{}, packed...), unpacked...)
+	m, err := Decode(bin, NotSort)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	checkPackedElements[int32](t, m, 1, protoreflect.Int32Kind)
+	checkPackedElements[int64](t, m, 2, protoreflect.Int64Kind)
+	checkPackedElements[uint32](t, m, 3, protoreflect.Uint32Kind)
+	checkPackedElements[uint64](t, m, 4, protoreflect.Uint64Kind)
+	checkPackedElements[int32](t, m, 5, protoreflect.Sint32Kind)
+	checkPackedElements[int64](t, m, 6, protoreflect.Sint64Kind)
+	checkPackedElements[bool](t, m, 7, protoreflect.BoolKind)
+	checkPackedElements[int32](t, m, 8, protoreflect.EnumKind)
+	checkPackedElements[uint64](t, m, 9, protoreflect.Fixed64Kind)
+	checkPackedElements[int64](t, m, 10, protoreflect.Sfixed64Kind)
+	checkPackedElements[float64](t, m, 11, protoreflect.DoubleKind)
+	checkPackedElements[uint32](t, m, 12, protoreflect.Fixed32Kind)
+	checkPackedElements[int32](t, m, 13, protoreflect.Sfixed32Kind)
+	checkPackedElements[float32](t, m, 14, protoreflect.FloatKind)
+
+	// 提前结束遍历
+	n := 0
+	for range PackedElements[int32](m, 1, protoreflect.Int32Kind) {
+		if n++; n == 2 {
+			break
+		}
+	}
+	for _, err := range PackedElements[uint32](m, 1, protoreflect.Int32Kind) {
+		if err != ErrTypeMismatch {
+			t.Fatalf("iterate int32 field as uint32 should fail, err: %+v", err)
+		}
+	}
+	for _, err := range PackedElements[int32](m, 15, protoreflect.StringKind) {
+		if err == nil {
+			t.Fatalf("iterate string field as packed elements should fail")
+		}
+	}
+	// packed数据按照float解析，之后未packed的varint元素解析失败
+	mixed, err := NewBuilder().PackedInt32(1, []int32{1, 2, 3, 4}).Int32(1, 5).Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	m, _ = Decode(mixed, NotSort)
+	var lastErr error
+	for _, err := range PackedElements[float32](m, 1, protoreflect.FloatKind) {
+		lastErr = err
+	}
+	if lastErr != ErrTypeMismatch {
+		t.Fatalf("iterate varint field as float should fail, err: %+v", lastErr)
+	}
+}
+
+func checkPackedElements[T any](t *testing.T, m ProtoMessage, tag protowire.Number, kind protoreflect.Kind) {
+	want, err := GetRepeated[T](m, tag, kind)
+	if err != nil {
+		t.Fatalf("can not parse tag %d, err: %+v", tag, err)
+	}
+	got := []T{}
+	for v, err := range PackedElements[T](m, tag, kind) {
+		if err != nil {
+			t.Fatalf("can not iterate tag %d, err: %+v", tag, err)
+		}
+		got = append(got, v)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("tag %d iterate result %v != parse result %v", tag, got, want)
+	}
+}
@@ -0,0 +1,64 @@
+package codec
+
+import (
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// lazyMessage 延迟解析的嵌套message，按照排序方式分别缓存解析结果
+type lazyMessage struct {
+	entries [Desc + 1]lazyEntry
+	// index 解析时是否建立tag索引
+	index bool
+}
+
+type lazyEntry struct {
+	once sync.Once
+	msg  ProtoMessage
+	err  error
+}
+
+// get 返回sortType对应的缓存结果，首次调用时通过decode解析
+func (l *lazyMessage) get(sortType MessageSortType, decode func() (ProtoMessage, error)) (ProtoMessage, error) {
+	if sortType < 0 || int(sortType) >= len(l.entries) {
+		return decode()
+	}
+	e := &l.entries[sortType]
+	e.once.Do(func() {
+		e.msg, e.err = decode()
+	})
+	return e.msg, e.err
+}
+
+// LazyMessage 延迟解析的嵌套message，首次调用Message时解析并缓存结果，复制LazyMessage时共享缓存
+type LazyMessage struct {
+	v ProtoValue
+}
+
+// Message 返回解析得到的嵌套message，字段按照升序排列，与UnpackedRepeatedMessageDecoder一致
+func (l LazyMessage) Message() (ProtoMessage, error) {
+	return l.v.DecodeEmbeddedMsg(Asc)
+}
+
+// Bytes 返回嵌套message的原始数据
+func (l LazyMessage) Bytes() []byte {
+	payload, _ := l.v.parseLen()
+	return payload
+}
+
+// UnpackedRepeatedLazyMessageDecoder 解码repeated message，返回[]LazyMessage，每个元素在首次访问时才解析
+var UnpackedRepeatedLazyMessageDecoder unpackedRepeatedDecoder = func(m ProtoMessage, idxs []int) (interface{}, error) {
+	result := make([]LazyMessage, 0, len(idxs))
+	for i := range idxs {
+		v := m.Values[idxs[i]]
+		if v._type != protowire.BytesType {
+			return nil, ErrTypeMismatch
+		}
+		if v.memo() == nil {
+			v.setMemo(&lazyMessage{})
+		}
+		result = append(result, LazyMessage{v: v})
+	}
+	return result, nil
+}
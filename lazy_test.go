@@ -0,0 +1,140 @@
+package codec
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/KarKLi/protobuf-golang-codec/internal/proto3_test"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDecodeLazy(t *testing.T) {
+	bin, err := proto.Marshal(testMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	m, err := DecodeWithOptions(bin, DecodeOptions{SortType: Asc, Lazy: true})
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	v14, err := m.GetData(14)
+	if err != nil {
+		t.Fatalf("can not get tag=14's data, err: %+v", err)
+	}
+	first, err := v14.DecodeEmbeddedMsg(Asc)
+	if err != nil {
+		t.Fatalf("can not parse tag 14, err: %+v", err)
+	}
+	// 复制得到的ProtoValue共享缓存
+	idxs, err := m.GetRepeatedData(14)
+	if err != nil {
+		t.Fatalf("can not get tag=14's data, err: %+v", err)
+	}
+	second, err := m.Values[idxs[0]].DecodeEmbeddedMsg(Asc)
+	if err != nil {
+		t.Fatalf("can not parse tag 14, err: %+v", err)
+	}
+	if &first.Values[0] != &second.Values[0] {
+		t.Fatalf("lazy decode result of tag 14 is not cached")
+	}
+	// 不同排序方式分别缓存
+	desc, err := v14.DecodeEmbeddedMsg(Desc)
+	if err != nil {
+		t.Fatalf("can not parse tag 14, err: %+v", err)
+	}
+	if desc.Values[0].tag != first.Values[len(first.Values)-1].tag {
+		t.Fatalf("unexpected sort result %d, %d", desc.Values[0].tag, first.Values[len(first.Values)-1].tag)
+	}
+	v3, err := first.GetData(3)
+	if err != nil {
+		t.Fatalf("can not get tag=14_tag 3's data, err: %+v", err)
+	}
+	realS, err := v3.DecodeString()
+	if err != nil || realS != testMsg.M_14.S_3 {
+		t.Fatalf("parse result %s != real val %s, err: %+v", realS, testMsg.M_14.S_3, err)
+	}
+	// 非延迟解析模式每次重新解析
+	eager, err := Decode(bin, Asc)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	v14, _ = eager.GetData(14)
+	first, _ = v14.DecodeEmbeddedMsg(Asc)
+	second, _ = v14.DecodeEmbeddedMsg(Asc)
+	if &first.Values[0] == &second.Values[0] {
+		t.Fatalf("eager decode result of tag 14 is cached")
+	}
+}
+
+func TestDecodeLazyInvalidEmbeddedMsg(t *testing.T) {
+	bin, err := NewBuilder().Uint64(1, 150).Bytes(2, []byte{0x1a, 0x05, 0x01}).Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	m, err := DecodeWithOptions(bin, DecodeOptions{Lazy: true, Spans: true})
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := m.Values[1].DecodeEmbeddedMsg(NotSort)
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expect *DecodeError, got %v", err)
+		}
+		if decodeErr.Offset != 5 || decodeErr.FieldPath() != "2.3" {
+			t.Fatalf("unexpected decode error %+v", decodeErr)
+		}
+	}
+}
+
+func TestUnpackedRepeatedLazyMessageDecoder(t *testing.T) {
+	bin, err := proto.Marshal(testUnpackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	m, err := Decode(bin, Asc)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	result, err := m.DecodeUnpackedRepeated(17, UnpackedRepeatedLazyMessageDecoder)
+	if err != nil {
+		t.Fatalf("decode tag 17 failed, err: %+v", err)
+	}
+	lazyMsgs := result.([]LazyMessage)
+	if len(lazyMsgs) != len(testUnpackedRepeatedMsg.M_17) {
+		t.Fatalf("lazy message count %d != %d", len(lazyMsgs), len(testUnpackedRepeatedMsg.M_17))
+	}
+	eager, err := m.DecodeUnpackedRepeated(17, UnpackedRepeatedMessageDecoder)
+	if err != nil {
+		t.Fatalf("decode tag 17 failed, err: %+v", err)
+	}
+	for i, lazy := range lazyMsgs {
+		msg, err := lazy.Message()
+		if err != nil {
+			t.Fatalf("decode tag 17[%d] failed, err: %+v", i, err)
+		}
+		expect, err := Encode(eager.([]ProtoMessage)[i])
+		if err != nil {
+			t.Fatalf("encode tag 17[%d] failed, err: %+v", i, err)
+		}
+		got, err := Encode(msg)
+		if err != nil {
+			t.Fatalf("encode tag 17[%d] failed, err: %+v", i, err)
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Fatalf("lazy message %v != eager message %v", got, expect)
+		}
+		embeeded := &proto3_test.Embeeded{}
+		if err := proto.Unmarshal(lazy.Bytes(), embeeded); err != nil {
+			t.Fatalf("can not unmarshal tag 17[%d], err: %+v", i, err)
+		}
+		if !proto.Equal(embeeded, testUnpackedRepeatedMsg.M_17[i]) {
+			t.Fatalf("tag 17[%d] %v != %v", i, embeeded, testUnpackedRepeatedMsg.M_17[i])
+		}
+	}
+	if _, err := m.DecodeUnpackedRepeated(1, UnpackedRepeatedLazyMessageDecoder); err != ErrTypeMismatch {
+		t.Fatalf("expect err %v, got %v", ErrTypeMismatch, err)
+	}
+}
@@ -2,7 +2,9 @@ package codec
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"reflect"
 
 	"google.golang.org/protobuf/encoding/protowire"
@@ -33,187 +35,276 @@ func FillMapFromProtoMapElem(elems []ProtoMapElem) (reflect.Value, error) {
 }
 
 // where the key_type can be any integral or string type (so, any scalar type except for floating point types and bytes)
-type keyDecoder func([]byte) ([]byte, ProtoMapKey, error)
+type keyDecoder struct {
+	// wireType key字段的wire type，wire type不一致的key字段视为未知字段跳过，缺省时按照该wire type的零值解析
+	wireType protowire.Type
+	decode   func([]byte) ([]byte, ProtoMapKey, error)
+}
+
+type valueDecoder struct {
+	// wireType value字段的wire type，wire type不一致的value字段视为未知字段跳过，缺省时按照该wire type的零值解析
+	wireType protowire.Type
+	decode   func([]byte) ([]byte, ProtoMapValue, error)
+}
+
+// NewKeyDecoder 创建自定义的keyDecoder，decode解析包含tag的key字段，返回剩余的数据
+func NewKeyDecoder(wireType protowire.Type, decode func([]byte) ([]byte, ProtoMapKey, error)) keyDecoder {
+	return keyDecoder{wireType: wireType, decode: decode}
+}
+
+// Decode 解析包含tag的key字段，返回剩余的数据
+func (d keyDecoder) Decode(b []byte) ([]byte, ProtoMapKey, error) {
+	return d.decode(b)
+}
+
+// WireType 返回key字段的wire type
+func (d keyDecoder) WireType() protowire.Type {
+	return d.wireType
+}
+
+// NewValueDecoder 创建自定义的valueDecoder，decode解析包含tag的value字段，返回剩余的数据
+func NewValueDecoder(wireType protowire.Type, decode func([]byte) ([]byte, ProtoMapValue, error)) valueDecoder {
+	return valueDecoder{wireType: wireType, decode: decode}
+}
 
-type valueDecoder func([]byte) ([]byte, ProtoMapValue, error)
+// Decode 解析包含tag的value字段，返回剩余的数据
+func (d valueDecoder) Decode(b []byte) ([]byte, ProtoMapValue, error) {
+	return d.decode(b)
+}
+
+// WireType 返回value字段的wire type
+func (d valueDecoder) WireType() protowire.Type {
+	return d.wireType
+}
 
-var Int32KeyDecoder keyDecoder = func(b []byte) ([]byte, ProtoMapKey, error) {
+var Int32KeyDecoder = NewKeyDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapKey, error) {
 	n, v, err := variantDecoder(b, keyTag)
 	if err != nil {
 		return nil, ProtoMapKey{}, err
 	}
 	return b[n:], ProtoMapKey{_type: protowire.VarintType, val: int32(v)}, nil
-}
+})
 
-var Int32ValueDecoder valueDecoder = func(b []byte) ([]byte, ProtoMapValue, error) {
+var Int32ValueDecoder = NewValueDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapValue, error) {
 	n, v, err := variantDecoder(b, valTag)
 	if err != nil {
 		return nil, ProtoMapValue{}, err
 	}
 	return b[n:], ProtoMapValue{_type: protowire.VarintType, val: int32(v)}, nil
-}
+})
 
-var Int64KeyDecoder keyDecoder = func(b []byte) ([]byte, ProtoMapKey, error) {
+var Int64KeyDecoder = NewKeyDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapKey, error) {
 	n, v, err := variantDecoder(b, keyTag)
 	if err != nil {
 		return nil, ProtoMapKey{}, err
 	}
 	return b[n:], ProtoMapKey{_type: protowire.VarintType, val: int64(v)}, nil
-}
+})
 
-var Int64ValueDecoder valueDecoder = func(b []byte) ([]byte, ProtoMapValue, error) {
+var Int64ValueDecoder = NewValueDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapValue, error) {
 	n, v, err := variantDecoder(b, valTag)
 	if err != nil {
 		return nil, ProtoMapValue{}, err
 	}
 	return b[n:], ProtoMapValue{_type: protowire.VarintType, val: int64(v)}, nil
-}
+})
 
-var Uint32KeyDecoder keyDecoder = func(b []byte) ([]byte, ProtoMapKey, error) {
+var Uint32KeyDecoder = NewKeyDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapKey, error) {
 	n, v, err := variantDecoder(b, keyTag)
 	if err != nil {
 		return nil, ProtoMapKey{}, err
 	}
 	return b[n:], ProtoMapKey{_type: protowire.VarintType, val: uint32(v)}, nil
-}
+})
 
-var Uint32ValueDecoder valueDecoder = func(b []byte) ([]byte, ProtoMapValue, error) {
+var Uint32ValueDecoder = NewValueDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapValue, error) {
 	n, v, err := variantDecoder(b, valTag)
 	if err != nil {
 		return nil, ProtoMapValue{}, err
 	}
 	return b[n:], ProtoMapValue{_type: protowire.VarintType, val: uint32(v)}, nil
-}
+})
 
-var Uint64KeyDecoder keyDecoder = func(b []byte) ([]byte, ProtoMapKey, error) {
+var Uint64KeyDecoder = NewKeyDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapKey, error) {
 	n, v, err := variantDecoder(b, keyTag)
 	if err != nil {
 		return nil, ProtoMapKey{}, err
 	}
 	return b[n:], ProtoMapKey{_type: protowire.VarintType, val: v}, nil
-}
+})
 
-var Uint64ValueDecoder valueDecoder = func(b []byte) ([]byte, ProtoMapValue, error) {
+var Uint64ValueDecoder = NewValueDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapValue, error) {
 	n, v, err := variantDecoder(b, valTag)
 	if err != nil {
 		return nil, ProtoMapValue{}, err
 	}
 	return b[n:], ProtoMapValue{_type: protowire.VarintType, val: v}, nil
-}
+})
 
-var Sint32KeyDecoder keyDecoder = func(b []byte) ([]byte, ProtoMapKey, error) {
+var Sint32KeyDecoder = NewKeyDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapKey, error) {
 	n, v, err := variantDecoder(b, keyTag)
 	if err != nil {
 		return nil, ProtoMapKey{}, err
 	}
 	return b[n:], ProtoMapKey{_type: protowire.VarintType, val: int32(protowire.DecodeZigZag(v))}, nil
-}
+})
 
-var Sint32ValueDecoder valueDecoder = func(b []byte) ([]byte, ProtoMapValue, error) {
+var Sint32ValueDecoder = NewValueDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapValue, error) {
 	n, v, err := variantDecoder(b, valTag)
 	if err != nil {
 		return nil, ProtoMapValue{}, err
 	}
 	return b[n:], ProtoMapValue{_type: protowire.VarintType, val: int32(protowire.DecodeZigZag(v))}, nil
-}
+})
 
-var Sint64KeyDecoder keyDecoder = func(b []byte) ([]byte, ProtoMapKey, error) {
+var Sint64KeyDecoder = NewKeyDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapKey, error) {
 	n, v, err := variantDecoder(b, keyTag)
 	if err != nil {
 		return nil, ProtoMapKey{}, err
 	}
 	return b[n:], ProtoMapKey{_type: protowire.VarintType, val: protowire.DecodeZigZag(v)}, nil
-}
+})
 
-var Sint64ValueDecoder valueDecoder = func(b []byte) ([]byte, ProtoMapValue, error) {
+var Sint64ValueDecoder = NewValueDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapValue, error) {
 	n, v, err := variantDecoder(b, valTag)
 	if err != nil {
 		return nil, ProtoMapValue{}, err
 	}
 	return b[n:], ProtoMapValue{_type: protowire.VarintType, val: protowire.DecodeZigZag(v)}, nil
-}
+})
 
-var BoolKeyDecoder keyDecoder = func(b []byte) ([]byte, ProtoMapKey, error) {
+var BoolKeyDecoder = NewKeyDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapKey, error) {
 	n, v, err := variantDecoder(b, keyTag)
 	if err != nil {
 		return nil, ProtoMapKey{}, err
 	}
 	return b[n:], ProtoMapKey{_type: protowire.VarintType, val: protowire.DecodeBool(v)}, nil
-}
+})
 
-var BoolValueDecoder valueDecoder = func(b []byte) ([]byte, ProtoMapValue, error) {
+var BoolValueDecoder = NewValueDecoder(protowire.VarintType, func(b []byte) ([]byte, ProtoMapValue, error) {
 	n, v, err := variantDecoder(b, valTag)
 	if err != nil {
 		return nil, ProtoMapValue{}, err
 	}
 	return b[n:], ProtoMapValue{_type: protowire.VarintType, val: protowire.DecodeBool(v)}, nil
-}
+})
 
-var Fixed64KeyDecoder keyDecoder = func(b []byte) ([]byte, ProtoMapKey, error) {
+var Fixed64KeyDecoder = NewKeyDecoder(protowire.Fixed64Type, func(b []byte) ([]byte, ProtoMapKey, error) {
 	n, v, err := i64Decoder(b, keyTag)
 	if err != nil {
 		return nil, ProtoMapKey{}, err
 	}
 	return b[n:], ProtoMapKey{_type: protowire.VarintType, val: v}, nil
-}
+})
 
-var Fixed64ValueDecoder valueDecoder = func(b []byte) ([]byte, ProtoMapValue, error) {
+var Fixed64ValueDecoder = NewValueDecoder(protowire.Fixed64Type, func(b []byte) ([]byte, ProtoMapValue, error) {
 	n, v, err := i64Decoder(b, valTag)
 	if err != nil {
 		return nil, ProtoMapValue{}, err
 	}
 	return b[n:], ProtoMapValue{_type: protowire.VarintType, val: v}, nil
-}
+})
 
-var Sfixed64KeyDecoder keyDecoder = func(b []byte) ([]byte, ProtoMapKey, error) {
+var Sfixed64KeyDecoder = NewKeyDecoder(protowire.Fixed64Type, func(b []byte) ([]byte, ProtoMapKey, error) {
 	n, v, err := i64Decoder(b, keyTag)
 	if err != nil {
 		return nil, ProtoMapKey{}, err
 	}
 	return b[n:], ProtoMapKey{_type: protowire.VarintType, val: int64(v)}, nil
-}
+})
 
-var Sfixed64ValueDecoder valueDecoder = func(b []byte) ([]byte, ProtoMapValue, error) {
+var Sfixed64ValueDecoder = NewValueDecoder(protowire.Fixed64Type, func(b []byte) ([]byte, ProtoMapValue, error) {
 	n, v, err := i64Decoder(b, valTag)
 	if err != nil {
 		return nil, ProtoMapValue{}, err
 	}
 	return b[n:], ProtoMapValue{_type: protowire.VarintType, val: int64(v)}, nil
-}
+})
 
-var StringKeyDecoder keyDecoder = func(b []byte) ([]byte, ProtoMapKey, error) {
+var Fixed32KeyDecoder = NewKeyDecoder(protowire.Fixed32Type, func(b []byte) ([]byte, ProtoMapKey, error) {
+	n, v, err := i32Decoder(b, keyTag)
+	if err != nil {
+		return nil, ProtoMapKey{}, err
+	}
+	return b[n:], ProtoMapKey{_type: protowire.Fixed32Type, val: v}, nil
+})
+
+var Fixed32ValueDecoder = NewValueDecoder(protowire.Fixed32Type, func(b []byte) ([]byte, ProtoMapValue, error) {
+	n, v, err := i32Decoder(b, valTag)
+	if err != nil {
+		return nil, ProtoMapValue{}, err
+	}
+	return b[n:], ProtoMapValue{_type: protowire.Fixed32Type, val: v}, nil
+})
+
+var Sfixed32KeyDecoder = NewKeyDecoder(protowire.Fixed32Type, func(b []byte) ([]byte, ProtoMapKey, error) {
+	n, v, err := i32Decoder(b, keyTag)
+	if err != nil {
+		return nil, ProtoMapKey{}, err
+	}
+	return b[n:], ProtoMapKey{_type: protowire.Fixed32Type, val: int32(v)}, nil
+})
+
+var Sfixed32ValueDecoder = NewValueDecoder(protowire.Fixed32Type, func(b []byte) ([]byte, ProtoMapValue, error) {
+	n, v, err := i32Decoder(b, valTag)
+	if err != nil {
+		return nil, ProtoMapValue{}, err
+	}
+	return b[n:], ProtoMapValue{_type: protowire.Fixed32Type, val: int32(v)}, nil
+})
+
+// EnumValueDecoder 与DecodeEnum一致，枚举值解析为int32
+var EnumValueDecoder = Int32ValueDecoder
+
+var FloatValueDecoder = NewValueDecoder(protowire.Fixed32Type, func(b []byte) ([]byte, ProtoMapValue, error) {
+	n, v, err := i32Decoder(b, valTag)
+	if err != nil {
+		return nil, ProtoMapValue{}, err
+	}
+	return b[n:], ProtoMapValue{_type: protowire.Fixed32Type, val: math.Float32frombits(v)}, nil
+})
+
+var DoubleValueDecoder = NewValueDecoder(protowire.Fixed64Type, func(b []byte) ([]byte, ProtoMapValue, error) {
+	n, v, err := i64Decoder(b, valTag)
+	if err != nil {
+		return nil, ProtoMapValue{}, err
+	}
+	return b[n:], ProtoMapValue{_type: protowire.Fixed64Type, val: math.Float64frombits(v)}, nil
+})
+
+var StringKeyDecoder = NewKeyDecoder(protowire.BytesType, func(b []byte) ([]byte, ProtoMapKey, error) {
 	n, v, err := lenDecoder(b, keyTag)
 	if err != nil {
 		return nil, ProtoMapKey{}, err
 	}
 	return b[n:], ProtoMapKey{_type: protowire.VarintType, val: string(v)}, nil
-}
+})
 
-var StringValueDecoder valueDecoder = func(b []byte) ([]byte, ProtoMapValue, error) {
+var StringValueDecoder = NewValueDecoder(protowire.BytesType, func(b []byte) ([]byte, ProtoMapValue, error) {
 	n, v, err := lenDecoder(b, valTag)
 	if err != nil {
 		return nil, ProtoMapValue{}, err
 	}
 	return b[n:], ProtoMapValue{_type: protowire.VarintType, val: string(v)}, nil
-}
+})
 
-var BytesKeyDecoder keyDecoder = func(b []byte) ([]byte, ProtoMapKey, error) {
+var BytesKeyDecoder = NewKeyDecoder(protowire.BytesType, func(b []byte) ([]byte, ProtoMapKey, error) {
 	n, v, err := lenDecoder(b, keyTag)
 	if err != nil {
 		return nil, ProtoMapKey{}, err
 	}
 	return b[n:], ProtoMapKey{_type: protowire.VarintType, val: v}, nil
-}
+})
 
-var BytesValueDecoder valueDecoder = func(b []byte) ([]byte, ProtoMapValue, error) {
+var BytesValueDecoder = NewValueDecoder(protowire.BytesType, func(b []byte) ([]byte, ProtoMapValue, error) {
 	n, v, err := lenDecoder(b, valTag)
 	if err != nil {
 		return nil, ProtoMapValue{}, err
 	}
 	return b[n:], ProtoMapValue{_type: protowire.VarintType, val: v}, nil
-}
+})
 
-var MessageValueDecoder valueDecoder = func(b []byte) ([]byte, ProtoMapValue, error) {
+var MessageValueDecoder = NewValueDecoder(protowire.BytesType, func(b []byte) ([]byte, ProtoMapValue, error) {
 	n, v, err := lenDecoder(b, valTag)
 	if err != nil {
 		return nil, ProtoMapValue{}, err
@@ -223,13 +314,78 @@ var MessageValueDecoder valueDecoder = func(b []byte) ([]byte, ProtoMapValue, er
 		return nil, ProtoMapValue{}, err
 	}
 	return b[n:], ProtoMapValue{_type: protowire.VarintType, val: msg}, nil
-}
+})
 
 const (
 	keyTag = protowire.Number(1)
 	valTag = protowire.Number(2)
 )
 
+// decodeMapEntry 解析一个map entry
+//
+// key和value可以缺省（使用对应类型的默认值）、以任意顺序出现或者重复出现（后出现的覆盖先出现的），
+// entry中的未知字段以及wire type与decoder不一致的key/value直接跳过，与protobuf-go一致
+func decodeMapEntry(b []byte, keyDec keyDecoder, valDec valueDecoder) (ProtoMapElem, error) {
+	var elem ProtoMapElem
+	keyField, valField, err := splitMapEntry(b, keyDec.wireType, valDec.wireType)
+	if err != nil {
+		return ProtoMapElem{}, err
+	}
+	err = decodeMapField(keyTag, keyDec.wireType, keyField, func(b []byte) (err error) {
+		_, elem.Key, err = keyDec.Decode(b)
+		return err
+	})
+	if err != nil {
+		return ProtoMapElem{}, err
+	}
+	err = decodeMapField(valTag, valDec.wireType, valField, func(b []byte) (err error) {
+		_, elem.Value, err = valDec.Decode(b)
+		return err
+	})
+	if err != nil {
+		return ProtoMapElem{}, err
+	}
+	return elem, nil
+}
+
+// splitMapEntry 返回map entry中wire type分别为keyType和valType的key和value最后一次出现的字段（包含tag），不存在时返回nil
+func splitMapEntry(b []byte, keyType, valType protowire.Type) ([]byte, []byte, error) {
+	var key, val []byte
+	for len(b) > 0 {
+		tag, typ, n := protowire.ConsumeField(b)
+		if n < 0 {
+			return nil, nil, protowire.ParseError(n)
+		}
+		switch {
+		case tag == keyTag && typ == keyType:
+			key = b[:n]
+		case tag == valTag && typ == valType:
+			val = b[:n]
+		}
+		b = b[n:]
+	}
+	return key, val, nil
+}
+
+// decodeMapField 解析map entry的key或value字段，字段缺省时按照typ对应的零值解析，得到对应类型的默认值
+func decodeMapField(tag protowire.Number, typ protowire.Type, field []byte, decode func([]byte) error) error {
+	if field != nil {
+		return decode(field)
+	}
+	b := protowire.AppendTag(nil, tag, typ)
+	switch typ {
+	case protowire.VarintType, protowire.BytesType:
+		b = protowire.AppendVarint(b, 0)
+	case protowire.Fixed64Type:
+		b = protowire.AppendFixed64(b, 0)
+	case protowire.Fixed32Type:
+		b = protowire.AppendFixed32(b, 0)
+	default:
+		return fmt.Errorf("not support proto map field wire type %d", typ)
+	}
+	return decode(b)
+}
+
 func baseDecoder(b []byte, _typ protowire.Type, _tag protowire.Number) (int, error) {
 	if len(b) == 0 {
 		return 0, io.EOF
@@ -275,6 +431,19 @@ func i64Decoder(b []byte, tag protowire.Number) (int, uint64, error) {
 	return m + n, v, nil
 }
 
+func i32Decoder(b []byte, tag protowire.Number) (int, uint32, error) {
+	m, err := baseDecoder(b, protowire.Fixed32Type, tag)
+	if err != nil {
+		return 0, 0, err
+	}
+	b = b[m:]
+	v, n := protowire.ConsumeFixed32(b)
+	if n < 0 {
+		return 0, 0, protowire.ParseError(n)
+	}
+	return m + n, v, nil
+}
+
 func lenDecoder(b []byte, tag protowire.Number) (int, []byte, error) {
 	m, err := baseDecoder(b, protowire.BytesType, tag)
 	if err != nil {
@@ -0,0 +1,344 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+	ErrNotMapType = errors.New("expected golang map type")
+)
+
+// 与keyDecoder/valueDecoder对应，将map entry的key（tag 1）或value（tag 2）追加编码到[]byte中
+type keyEncoder func([]byte, interface{}) ([]byte, error)
+
+type valueEncoder func([]byte, interface{}) ([]byte, error)
+
+var Int32KeyEncoder keyEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(int32)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return variantEncoder(b, keyTag, uint64(val)), nil
+}
+
+var Int32ValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(int32)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return variantEncoder(b, valTag, uint64(val)), nil
+}
+
+var Int64KeyEncoder keyEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(int64)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return variantEncoder(b, keyTag, uint64(val)), nil
+}
+
+var Int64ValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(int64)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return variantEncoder(b, valTag, uint64(val)), nil
+}
+
+var Uint32KeyEncoder keyEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(uint32)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return variantEncoder(b, keyTag, uint64(val)), nil
+}
+
+var Uint32ValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(uint32)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return variantEncoder(b, valTag, uint64(val)), nil
+}
+
+var Uint64KeyEncoder keyEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(uint64)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return variantEncoder(b, keyTag, val), nil
+}
+
+var Uint64ValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(uint64)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return variantEncoder(b, valTag, val), nil
+}
+
+var Sint32KeyEncoder keyEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(int32)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return variantEncoder(b, keyTag, protowire.EncodeZigZag(int64(val))), nil
+}
+
+var Sint32ValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(int32)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return variantEncoder(b, valTag, protowire.EncodeZigZag(int64(val))), nil
+}
+
+var Sint64KeyEncoder keyEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(int64)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return variantEncoder(b, keyTag, protowire.EncodeZigZag(val)), nil
+}
+
+var Sint64ValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(int64)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return variantEncoder(b, valTag, protowire.EncodeZigZag(val)), nil
+}
+
+var BoolKeyEncoder keyEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(bool)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return variantEncoder(b, keyTag, protowire.EncodeBool(val)), nil
+}
+
+var BoolValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(bool)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return variantEncoder(b, valTag, protowire.EncodeBool(val)), nil
+}
+
+var Fixed64KeyEncoder keyEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(uint64)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return i64Encoder(b, keyTag, val), nil
+}
+
+var Fixed64ValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(uint64)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return i64Encoder(b, valTag, val), nil
+}
+
+var Sfixed64KeyEncoder keyEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(int64)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return i64Encoder(b, keyTag, uint64(val)), nil
+}
+
+var Sfixed64ValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(int64)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return i64Encoder(b, valTag, uint64(val)), nil
+}
+
+var Fixed32KeyEncoder keyEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(uint32)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return i32Encoder(b, keyTag, val), nil
+}
+
+var Fixed32ValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(uint32)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return i32Encoder(b, valTag, val), nil
+}
+
+var Sfixed32KeyEncoder keyEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(int32)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return i32Encoder(b, keyTag, uint32(val)), nil
+}
+
+var Sfixed32ValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(int32)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return i32Encoder(b, valTag, uint32(val)), nil
+}
+
+// EnumValueEncoder 与EnumValueDecoder对应，枚举值使用int32表示
+var EnumValueEncoder = Int32ValueEncoder
+
+var FloatValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(float32)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return i32Encoder(b, valTag, math.Float32bits(val)), nil
+}
+
+var DoubleValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(float64)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return i64Encoder(b, valTag, math.Float64bits(val)), nil
+}
+
+var StringKeyEncoder keyEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(string)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return lenEncoder(b, keyTag, []byte(val)), nil
+}
+
+var StringValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.(string)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return lenEncoder(b, valTag, []byte(val)), nil
+}
+
+// BytesKeyEncoder 编码bytes类型的key，v可以是[]byte、string或[N]byte
+//
+// golang map的key不能是[]byte，通过Map写入时使用string或[N]byte作为key，按照字节序排序
+var BytesKeyEncoder keyEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case []byte:
+		return lenEncoder(b, keyTag, val), nil
+	case string:
+		return lenEncoder(b, keyTag, []byte(val)), nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Array || rv.Type().Elem().Kind() != reflect.Uint8 {
+		return nil, ErrAssertTypeFailed
+	}
+	return lenEncoder(b, keyTag, byteArray(rv)), nil
+}
+
+var BytesValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	val, ok := v.([]byte)
+	if !ok {
+		return nil, ErrAssertTypeFailed
+	}
+	return lenEncoder(b, valTag, val), nil
+}
+
+// MessageValueEncoder 编码message类型的value，v可以是ProtoMessage或*MessageBuilder
+var MessageValueEncoder valueEncoder = func(b []byte, v interface{}) ([]byte, error) {
+	var payload []byte
+	var err error
+	switch val := v.(type) {
+	case ProtoMessage:
+		payload, err = val.Marshal()
+	case *MessageBuilder:
+		payload, err = val.Marshal()
+	default:
+		return nil, ErrAssertTypeFailed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lenEncoder(b, valTag, payload), nil
+}
+
+func variantEncoder(b []byte, tag protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, tag, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func i64Encoder(b []byte, tag protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, tag, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+func i32Encoder(b []byte, tag protowire.Number, v uint32) []byte {
+	b = protowire.AppendTag(b, tag, protowire.Fixed32Type)
+	return protowire.AppendFixed32(b, v)
+}
+
+func lenEncoder(b []byte, tag protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, tag, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// encodeMapEntries 将golang map中的每个键值对编码为map entry的payload，按key排序返回
+func encodeMapEntries(m interface{}, keyEnc keyEncoder, valEnc valueEncoder) ([][]byte, error) {
+	mv := reflect.ValueOf(m)
+	if mv.Kind() != reflect.Map {
+		return nil, ErrNotMapType
+	}
+	keys := mv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return mapKeyLess(keys[i], keys[j])
+	})
+	entries := make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		entry, err := keyEnc(nil, k.Interface())
+		if err != nil {
+			return nil, err
+		}
+		entry, err = valEnc(entry, mv.MapIndex(k).Interface())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func mapKeyLess(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return a.Uint() < b.Uint()
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	case reflect.Array:
+		if a.Type().Elem().Kind() == reflect.Uint8 {
+			return bytes.Compare(byteArray(a), byteArray(b)) < 0
+		}
+	}
+	return false
+}
+
+// byteArray 返回[N]byte类型的v中的数据
+func byteArray(v reflect.Value) []byte {
+	b := make([]byte, v.Len())
+	reflect.Copy(reflect.ValueOf(b), v)
+	return b
+}
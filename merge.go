@@ -0,0 +1,61 @@
+package codec
+
+import "google.golang.org/protobuf/encoding/protowire"
+
+// GetLastData 返回tag最后一次出现的字段，不存在时返回空ProtoValue
+//
+// 与proto.Unmarshal处理重复的singular标量字段的方式一致：后出现的值覆盖先出现的值。
+// 重复的singular嵌套message字段需要合并，应当使用GetMergedMessage
+func (p *ProtoMessage) GetLastData(tag protowire.Number) (ProtoValue, error) {
+	if p.index == nil && p.sortType == NotSort {
+		v, _ := lastField(*p, tag)
+		return v, nil
+	}
+	idxs, err := p.GetRepeatedData(tag)
+	if err != nil || len(idxs) == 0 {
+		return ProtoValue{}, err
+	}
+	return p.Values[idxs[len(idxs)-1]], nil
+}
+
+// GetMergedMessage 将tag的所有嵌套message（BytesType）或group按照出现顺序合并为一个message，tag不存在时返回空message
+//
+// 与proto.Unmarshal处理重复的singular嵌套message字段的方式一致。合并后的message中，
+// singular标量字段通过GetLastData获取，singular嵌套message字段继续通过GetMergedMessage获取
+func (p *ProtoMessage) GetMergedMessage(tag protowire.Number, sortType MessageSortType) (ProtoMessage, error) {
+	idxs, err := p.GetRepeatedData(tag)
+	if err != nil {
+		return ProtoMessage{}, err
+	}
+	merged := ProtoMessage{Values: make([]ProtoValue, 0, 16)}
+	for _, i := range idxs {
+		var sub ProtoMessage
+		switch v := p.Values[i]; v._type {
+		case protowire.BytesType:
+			sub, err = v.DecodeEmbeddedMsg(NotSort)
+		case protowire.StartGroupType:
+			sub, err = v.parseGroup()
+		default:
+			err = ErrTypeMismatch
+		}
+		if err != nil {
+			return ProtoMessage{}, err
+		}
+		merged.Values = append(merged.Values, sub.Values...)
+	}
+	merged.sortType = sortType
+	merged.sort()
+	return merged, nil
+}
+
+// Merge 将src的字段追加到p中，与将两段proto二进制流数据拼接后解析的结果一致
+//
+// 合并后按照p的排序方式重新排序，已经建立的索引同样重新建立
+func (p *ProtoMessage) Merge(src ProtoMessage) {
+	// 不修改与其他ProtoMessage共享的底层数组
+	p.Values = append(p.Values[:len(p.Values):len(p.Values)], src.Values...)
+	p.sort()
+	if p.index != nil {
+		p.BuildIndex()
+	}
+}
@@ -0,0 +1,130 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/KarKLi/protobuf-golang-codec/internal/proto3_test"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// testDuplicateBin 重复的singular字段，与其他实现拼接多段数据得到的结果一致
+func testDuplicateBin(t *testing.T) []byte {
+	bin, err := NewBuilder().
+		Int32(1, 5).
+		Message(14, NewBuilder().Int32(1, 1).String(3, "a")).
+		String(12, "x").
+		Int32(1, 7).
+		Message(14, NewBuilder().Fixed64(2, 9).String(3, "b")).
+		String(12, "y").
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	return bin
+}
+
+func TestLastWins(t *testing.T) {
+	bin := testDuplicateBin(t)
+	expect := &proto3_test.Msg{}
+	if err := proto.Unmarshal(bin, expect); err != nil {
+		t.Fatalf("can not unmarshal test proto message, err: %+v", err)
+	}
+	for _, opts := range []DecodeOptions{{SortType: NotSort}, {SortType: Asc}, {SortType: Desc}, {SortType: Asc, Index: true}, {Lazy: true}} {
+		m, err := DecodeWithOptions(bin, opts)
+		if err != nil {
+			t.Fatalf("decode test proto message failed, err: %+v", err)
+		}
+		v1, err := m.GetLastData(1)
+		if err != nil {
+			t.Fatalf("can not get tag=1's data, err: %+v", err)
+		}
+		if realI, err := v1.DecodeInt32(); err != nil || realI != expect.I_1 {
+			t.Fatalf("opts %+v parse result %d != real val %d, err: %+v", opts, realI, expect.I_1, err)
+		}
+		v12, _ := m.GetLastData(12)
+		if realS, err := v12.DecodeString(); err != nil || realS != expect.S_12 {
+			t.Fatalf("opts %+v parse result %s != real val %s, err: %+v", opts, realS, expect.S_12, err)
+		}
+		if v, err := m.GetLastData(2); err != nil || v.tag != 0 {
+			t.Fatalf("get missing tag 2 returns %+v, err: %+v", v, err)
+		}
+		sub, err := m.GetMergedMessage(14, Asc)
+		if err != nil {
+			t.Fatalf("can not merge tag 14, err: %+v", err)
+		}
+		got := &proto3_test.Embeeded{}
+		for tag, dst := range map[protowire.Number]interface{}{1: &got.I_1, 2: &got.F_2, 3: &got.S_3} {
+			v, _ := sub.GetLastData(tag)
+			switch dst := dst.(type) {
+			case *int32:
+				*dst, err = v.DecodeInt32()
+			case *uint64:
+				*dst, err = v.DecodeFixed64()
+			case *string:
+				*dst, err = v.DecodeString()
+			}
+			if err != nil {
+				t.Fatalf("can not parse merged tag %d, err: %+v", tag, err)
+			}
+		}
+		if !proto.Equal(got, expect.M_14) {
+			t.Fatalf("opts %+v merged result %v != real val %v", opts, got, expect.M_14)
+		}
+	}
+
+	m, _ := Decode(bin, NotSort)
+	if sub, err := m.GetMergedMessage(15, NotSort); err != nil || len(sub.Values) != 0 {
+		t.Fatalf("merge missing tag should return empty message, got %+v, err: %+v", sub, err)
+	}
+	if _, err := m.GetMergedMessage(1, NotSort); err != ErrTypeMismatch {
+		t.Fatalf("merge varint tag should fail, err: %+v", err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	first, err := NewBuilder().Int32(1, 5).String(12, "x").Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	second, err := NewBuilder().Int32(1, 7).Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	// 降序排列的message同样能够二分查找
+	desc, _ := Decode(first, Desc)
+	if v12, err := desc.GetData(12); err != nil || v12.tag != 12 {
+		t.Fatalf("can not get tag=12's data from desc sorted message, got %+v, err: %+v", v12, err)
+	}
+	m, _ := DecodeWithOptions(first, DecodeOptions{SortType: Desc, Index: true})
+	shared := m
+	src, _ := Decode(second, NotSort)
+	m.Merge(src)
+	if len(shared.Values) != 2 || len(m.Values) != 3 {
+		t.Fatalf("merge should not modify shared values, got %d and %d values", len(shared.Values), len(m.Values))
+	}
+	v1, _ := m.GetLastData(1)
+	if realI, err := v1.DecodeInt32(); err != nil || realI != 7 {
+		t.Fatalf("parse result %d != real val 7, err: %+v", realI, err)
+	}
+	if idxs, _ := m.GetRepeatedData(1); len(idxs) != 2 {
+		t.Fatalf("index not rebuilt after merge, got %v", idxs)
+	}
+}
+
+func TestDecodeWithDescriptorMergeMessage(t *testing.T) {
+	bin := testDuplicateBin(t)
+	expect := &proto3_test.Msg{}
+	if err := proto.Unmarshal(bin, expect); err != nil {
+		t.Fatalf("can not unmarshal test proto message, err: %+v", err)
+	}
+	m, err := DecodeWithDescriptor(bin, expect.ProtoReflect().Descriptor())
+	if err != nil {
+		t.Fatalf("decode test proto message with descriptor failed, err: %+v", err)
+	}
+	v, ok := m.Get("m_14")
+	if !ok {
+		t.Fatalf("field m_14 not found")
+	}
+	checkTypedEmbeededEqual(t, v, expect.M_14)
+}
@@ -6,12 +6,33 @@ import (
 	"google.golang.org/protobuf/encoding/protowire"
 )
 
-type packedRepeatedDecoder func(ProtoValue) (interface{}, error)
+// packedRepeatedDecoder 解码[packed=true]的repeated字段，wireType为元素未packed时的wire type
+type packedRepeatedDecoder struct {
+	wireType protowire.Type
+	decode   func(ProtoValue) (interface{}, error)
+}
+
+// NewPackedRepeatedDecoder 创建自定义的packedRepeatedDecoder，decode解码拼接后的packed数据
+//
+// wireType为元素未packed时的wire type，DecodePackedRepeated据此拼接未packed的元素；为BytesType时只接受packed数据
+func NewPackedRepeatedDecoder(wireType protowire.Type, decode func(ProtoValue) (interface{}, error)) packedRepeatedDecoder {
+	return packedRepeatedDecoder{wireType: wireType, decode: decode}
+}
+
+// Decode 解码packed数据，p的wire type必须为BytesType
+func (d packedRepeatedDecoder) Decode(p ProtoValue) (interface{}, error) {
+	return d.decode(p)
+}
+
+// WireType 返回元素未packed时的wire type
+func (d packedRepeatedDecoder) WireType() protowire.Type {
+	return d.wireType
+}
 
 // 标记为packed的packedRepeatedDecoder，仅适用于wire_type为VARINT的数字类型
 
 // PackedRepeatedInt32Decoder 解码repeated int32
-var PackedRepeatedInt32Decoder packedRepeatedDecoder = func(p ProtoValue) (interface{}, error) {
+var PackedRepeatedInt32Decoder = NewPackedRepeatedDecoder(protowire.VarintType, func(p ProtoValue) (interface{}, error) {
 	result := []int32{}
 
 	payload := p.val.([]byte)
@@ -24,10 +45,10 @@ var PackedRepeatedInt32Decoder packedRepeatedDecoder = func(p ProtoValue) (inter
 		payload = payload[n:]
 	}
 	return result, nil
-}
+})
 
 // PackedRepeatedInt64Decoder 解码repeated int64
-var PackedRepeatedInt64Decoder packedRepeatedDecoder = func(p ProtoValue) (interface{}, error) {
+var PackedRepeatedInt64Decoder = NewPackedRepeatedDecoder(protowire.VarintType, func(p ProtoValue) (interface{}, error) {
 	result := []int64{}
 
 	payload := p.val.([]byte)
@@ -40,10 +61,10 @@ var PackedRepeatedInt64Decoder packedRepeatedDecoder = func(p ProtoValue) (inter
 		payload = payload[n:]
 	}
 	return result, nil
-}
+})
 
 // PackedRepeatedUint32Decoder 解码repeated uint32
-var PackedRepeatedUint32Decoder packedRepeatedDecoder = func(p ProtoValue) (interface{}, error) {
+var PackedRepeatedUint32Decoder = NewPackedRepeatedDecoder(protowire.VarintType, func(p ProtoValue) (interface{}, error) {
 	result := []uint32{}
 
 	payload := p.val.([]byte)
@@ -56,10 +77,10 @@ var PackedRepeatedUint32Decoder packedRepeatedDecoder = func(p ProtoValue) (inte
 		payload = payload[n:]
 	}
 	return result, nil
-}
+})
 
 // PackedRepeatedUint64Decoder 解码repeated uint64
-var PackedRepeatedUint64Decoder packedRepeatedDecoder = func(p ProtoValue) (interface{}, error) {
+var PackedRepeatedUint64Decoder = NewPackedRepeatedDecoder(protowire.VarintType, func(p ProtoValue) (interface{}, error) {
 	result := []uint64{}
 
 	payload := p.val.([]byte)
@@ -72,10 +93,10 @@ var PackedRepeatedUint64Decoder packedRepeatedDecoder = func(p ProtoValue) (inte
 		payload = payload[n:]
 	}
 	return result, nil
-}
+})
 
 // PackedRepeatedSint32Decoder 解码repeated sint32
-var PackedRepeatedSint32Decoder packedRepeatedDecoder = func(p ProtoValue) (interface{}, error) {
+var PackedRepeatedSint32Decoder = NewPackedRepeatedDecoder(protowire.VarintType, func(p ProtoValue) (interface{}, error) {
 	result := []int32{}
 
 	payload := p.val.([]byte)
@@ -88,10 +109,10 @@ var PackedRepeatedSint32Decoder packedRepeatedDecoder = func(p ProtoValue) (inte
 		payload = payload[n:]
 	}
 	return result, nil
-}
+})
 
 // PackedRepeatedSint64Decoder 解码repeated sint64
-var PackedRepeatedSint64Decoder packedRepeatedDecoder = func(p ProtoValue) (interface{}, error) {
+var PackedRepeatedSint64Decoder = NewPackedRepeatedDecoder(protowire.VarintType, func(p ProtoValue) (interface{}, error) {
 	result := []int64{}
 
 	payload := p.val.([]byte)
@@ -104,10 +125,10 @@ var PackedRepeatedSint64Decoder packedRepeatedDecoder = func(p ProtoValue) (inte
 		payload = payload[n:]
 	}
 	return result, nil
-}
+})
 
 // PackedRepeatedBoolDecoder 解码repeated bool
-var PackedRepeatedBoolDecoder packedRepeatedDecoder = func(p ProtoValue) (interface{}, error) {
+var PackedRepeatedBoolDecoder = NewPackedRepeatedDecoder(protowire.VarintType, func(p ProtoValue) (interface{}, error) {
 	result := []bool{}
 
 	payload := p.val.([]byte)
@@ -120,15 +141,15 @@ var PackedRepeatedBoolDecoder packedRepeatedDecoder = func(p ProtoValue) (interf
 		payload = payload[n:]
 	}
 	return result, nil
-}
+})
 
 // PackedRepeatedEnumDecoder 解码repeated enum（本质是[]int32）
-var PackedRepeatedEnumDecoder packedRepeatedDecoder = func(p ProtoValue) (interface{}, error) {
-	return PackedRepeatedInt32Decoder(p)
-}
+var PackedRepeatedEnumDecoder = NewPackedRepeatedDecoder(protowire.VarintType, func(p ProtoValue) (interface{}, error) {
+	return PackedRepeatedInt32Decoder.Decode(p)
+})
 
 // PackedRepeatedFixed64Decoder 解码repeated fixed64
-var PackedRepeatedFixed64Decoder packedRepeatedDecoder = func(p ProtoValue) (interface{}, error) {
+var PackedRepeatedFixed64Decoder = NewPackedRepeatedDecoder(protowire.Fixed64Type, func(p ProtoValue) (interface{}, error) {
 	result := []uint64{}
 
 	payload := p.val.([]byte)
@@ -141,10 +162,10 @@ var PackedRepeatedFixed64Decoder packedRepeatedDecoder = func(p ProtoValue) (int
 		payload = payload[n:]
 	}
 	return result, nil
-}
+})
 
 // PackedRepeatedSfixed64Decoder 解码repeated sfixed64
-var PackedRepeatedSfixed64Decoder packedRepeatedDecoder = func(p ProtoValue) (interface{}, error) {
+var PackedRepeatedSfixed64Decoder = NewPackedRepeatedDecoder(protowire.Fixed64Type, func(p ProtoValue) (interface{}, error) {
 	result := []int64{}
 
 	payload := p.val.([]byte)
@@ -157,10 +178,10 @@ var PackedRepeatedSfixed64Decoder packedRepeatedDecoder = func(p ProtoValue) (in
 		payload = payload[n:]
 	}
 	return result, nil
-}
+})
 
 // PackedRepeatedDoubleDecoder 解码repeated double
-var PackedRepeatedDoubleDecoder packedRepeatedDecoder = func(p ProtoValue) (interface{}, error) {
+var PackedRepeatedDoubleDecoder = NewPackedRepeatedDecoder(protowire.Fixed64Type, func(p ProtoValue) (interface{}, error) {
 	result := []float64{}
 
 	payload := p.val.([]byte)
@@ -173,10 +194,10 @@ var PackedRepeatedDoubleDecoder packedRepeatedDecoder = func(p ProtoValue) (inte
 		payload = payload[n:]
 	}
 	return result, nil
-}
+})
 
 // PackedRepeatedFixed32Decoder 解码repeated fixed32
-var PackedRepeatedFixed32Decoder packedRepeatedDecoder = func(p ProtoValue) (interface{}, error) {
+var PackedRepeatedFixed32Decoder = NewPackedRepeatedDecoder(protowire.Fixed32Type, func(p ProtoValue) (interface{}, error) {
 	result := []uint32{}
 
 	payload := p.val.([]byte)
@@ -189,10 +210,10 @@ var PackedRepeatedFixed32Decoder packedRepeatedDecoder = func(p ProtoValue) (int
 		payload = payload[n:]
 	}
 	return result, nil
-}
+})
 
 // PackedRepeatedSfixed32Decoder 解码repeated sfixed32
-var PackedRepeatedSfixed32Decoder packedRepeatedDecoder = func(p ProtoValue) (interface{}, error) {
+var PackedRepeatedSfixed32Decoder = NewPackedRepeatedDecoder(protowire.Fixed32Type, func(p ProtoValue) (interface{}, error) {
 	result := []int32{}
 
 	payload := p.val.([]byte)
@@ -205,10 +226,10 @@ var PackedRepeatedSfixed32Decoder packedRepeatedDecoder = func(p ProtoValue) (in
 		payload = payload[n:]
 	}
 	return result, nil
-}
+})
 
 // PackedRepeatedFloatDecoder 解码repeated float
-var PackedRepeatedFloatDecoder packedRepeatedDecoder = func(p ProtoValue) (interface{}, error) {
+var PackedRepeatedFloatDecoder = NewPackedRepeatedDecoder(protowire.Fixed32Type, func(p ProtoValue) (interface{}, error) {
 	result := []float32{}
 
 	payload := p.val.([]byte)
@@ -221,4 +242,4 @@ var PackedRepeatedFloatDecoder packedRepeatedDecoder = func(p ProtoValue) (inter
 		payload = payload[n:]
 	}
 	return result, nil
-}
+})
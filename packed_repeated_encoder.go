@@ -0,0 +1,139 @@
+package codec
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// addPacked 写入编码后的[packed=true]的repeated字段，与packedRepeatedDecoder对应
+//
+// 与proto.Marshal行为一致，空切片（payload为空）不写入任何数据
+func (b *MessageBuilder) addPacked(tag protowire.Number, payload []byte) *MessageBuilder {
+	if len(payload) == 0 {
+		return b
+	}
+	return b.add(tag, protowire.BytesType, payload)
+}
+
+// PackedInt32 写入repeated int32
+func (b *MessageBuilder) PackedInt32(tag protowire.Number, vals []int32) *MessageBuilder {
+	var payload []byte
+	for _, v := range vals {
+		payload = protowire.AppendVarint(payload, uint64(v))
+	}
+	return b.addPacked(tag, payload)
+}
+
+// PackedInt64 写入repeated int64
+func (b *MessageBuilder) PackedInt64(tag protowire.Number, vals []int64) *MessageBuilder {
+	var payload []byte
+	for _, v := range vals {
+		payload = protowire.AppendVarint(payload, uint64(v))
+	}
+	return b.addPacked(tag, payload)
+}
+
+// PackedUint32 写入repeated uint32
+func (b *MessageBuilder) PackedUint32(tag protowire.Number, vals []uint32) *MessageBuilder {
+	var payload []byte
+	for _, v := range vals {
+		payload = protowire.AppendVarint(payload, uint64(v))
+	}
+	return b.addPacked(tag, payload)
+}
+
+// PackedUint64 写入repeated uint64
+func (b *MessageBuilder) PackedUint64(tag protowire.Number, vals []uint64) *MessageBuilder {
+	var payload []byte
+	for _, v := range vals {
+		payload = protowire.AppendVarint(payload, v)
+	}
+	return b.addPacked(tag, payload)
+}
+
+// PackedSint32 写入repeated sint32
+func (b *MessageBuilder) PackedSint32(tag protowire.Number, vals []int32) *MessageBuilder {
+	var payload []byte
+	for _, v := range vals {
+		payload = protowire.AppendVarint(payload, protowire.EncodeZigZag(int64(v)))
+	}
+	return b.addPacked(tag, payload)
+}
+
+// PackedSint64 写入repeated sint64
+func (b *MessageBuilder) PackedSint64(tag protowire.Number, vals []int64) *MessageBuilder {
+	var payload []byte
+	for _, v := range vals {
+		payload = protowire.AppendVarint(payload, protowire.EncodeZigZag(v))
+	}
+	return b.addPacked(tag, payload)
+}
+
+// PackedBool 写入repeated bool
+func (b *MessageBuilder) PackedBool(tag protowire.Number, vals []bool) *MessageBuilder {
+	var payload []byte
+	for _, v := range vals {
+		payload = protowire.AppendVarint(payload, protowire.EncodeBool(v))
+	}
+	return b.addPacked(tag, payload)
+}
+
+// PackedEnum 写入repeated enum（本质是[]int32）
+func (b *MessageBuilder) PackedEnum(tag protowire.Number, vals []int32) *MessageBuilder {
+	return b.PackedInt32(tag, vals)
+}
+
+// PackedFixed64 写入repeated fixed64
+func (b *MessageBuilder) PackedFixed64(tag protowire.Number, vals []uint64) *MessageBuilder {
+	var payload []byte
+	for _, v := range vals {
+		payload = protowire.AppendFixed64(payload, v)
+	}
+	return b.addPacked(tag, payload)
+}
+
+// PackedSfixed64 写入repeated sfixed64
+func (b *MessageBuilder) PackedSfixed64(tag protowire.Number, vals []int64) *MessageBuilder {
+	var payload []byte
+	for _, v := range vals {
+		payload = protowire.AppendFixed64(payload, uint64(v))
+	}
+	return b.addPacked(tag, payload)
+}
+
+// PackedDouble 写入repeated double
+func (b *MessageBuilder) PackedDouble(tag protowire.Number, vals []float64) *MessageBuilder {
+	var payload []byte
+	for _, v := range vals {
+		payload = protowire.AppendFixed64(payload, math.Float64bits(v))
+	}
+	return b.addPacked(tag, payload)
+}
+
+// PackedFixed32 写入repeated fixed32
+func (b *MessageBuilder) PackedFixed32(tag protowire.Number, vals []uint32) *MessageBuilder {
+	var payload []byte
+	for _, v := range vals {
+		payload = protowire.AppendFixed32(payload, v)
+	}
+	return b.addPacked(tag, payload)
+}
+
+// PackedSfixed32 写入repeated sfixed32
+func (b *MessageBuilder) PackedSfixed32(tag protowire.Number, vals []int32) *MessageBuilder {
+	var payload []byte
+	for _, v := range vals {
+		payload = protowire.AppendFixed32(payload, uint32(v))
+	}
+	return b.addPacked(tag, payload)
+}
+
+// PackedFloat 写入repeated float
+func (b *MessageBuilder) PackedFloat(tag protowire.Number, vals []float32) *MessageBuilder {
+	var payload []byte
+	for _, v := range vals {
+		payload = protowire.AppendFixed32(payload, math.Float32bits(v))
+	}
+	return b.addPacked(tag, payload)
+}
@@ -0,0 +1,475 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+	ErrInvalidPath  = errors.New("invalid field path")
+	ErrPathNotFound = errors.New("field path not found")
+)
+
+// pathSegment 字段路径中的一段，例如14、17[1]或18{key}
+type pathSegment struct {
+	tag protowire.Number
+	// index repeated字段的下标，负数表示从末尾开始计数
+	index    int
+	hasIndex bool
+	// key map字段的key
+	key    string
+	hasKey bool
+}
+
+// parsePath 解析以.分隔的字段路径，例如14.3、17[1].3、18{key}
+func parsePath(path string) ([]pathSegment, error) {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w %q: %s", ErrInvalidPath, path, reason)
+	}
+	var segs []pathSegment
+	rest := path
+	for {
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return nil, invalid("expect tag")
+		}
+		tag, err := strconv.ParseInt(rest[:i], 10, 32)
+		if err != nil || !protowire.Number(tag).IsValid() {
+			return nil, invalid("invalid tag " + rest[:i])
+		}
+		seg := pathSegment{tag: protowire.Number(tag)}
+		rest = rest[i:]
+		if len(rest) > 0 && (rest[0] == '[' || rest[0] == '{') {
+			end := byte(']')
+			if rest[0] == '{' {
+				end = '}'
+			}
+			j := strings.IndexByte(rest, end)
+			if j < 0 {
+				return nil, invalid("missing " + string(end))
+			}
+			if end == ']' {
+				if seg.index, err = strconv.Atoi(rest[1:j]); err != nil {
+					return nil, invalid("invalid index " + rest[1:j])
+				}
+				seg.hasIndex = true
+			} else {
+				seg.key, seg.hasKey = rest[1:j], true
+			}
+			rest = rest[j+1:]
+		}
+		segs = append(segs, seg)
+		if rest == "" {
+			return segs, nil
+		}
+		if rest[0] != '.' {
+			return nil, invalid("unexpected " + rest[:1])
+		}
+		rest = rest[1:]
+	}
+}
+
+// GetPath 根据字段路径获取所有匹配的字段
+//
+// 路径由.分隔的tag组成，例如14.3表示tag 14的嵌套message中的tag 3；
+// tag后可以带有[i]选择repeated字段中的第i个（负数表示从末尾开始计数），或带有{key}选择map中key对应的value。
+// 不带下标的repeated字段匹配所有元素，后续路径分别在每个元素中查找。
+// 路径中间的字段必须是嵌套message（BytesType）或group，不存在时返回空结果
+func (p *ProtoMessage) GetPath(path string) ([]ProtoValue, error) {
+	segs, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	msgs := []ProtoMessage{*p}
+	var vals []ProtoValue
+	for i, seg := range segs {
+		vals = nil
+		for _, m := range msgs {
+			found, err := seg.lookup(m)
+			if err != nil {
+				return nil, fmt.Errorf("get path %s failed: %w", path, err)
+			}
+			vals = append(vals, found...)
+		}
+		if i == len(segs)-1 {
+			break
+		}
+		msgs = make([]ProtoMessage, 0, len(vals))
+		for _, v := range vals {
+			m, err := embeddedMessage(v)
+			if err != nil {
+				return nil, fmt.Errorf("get path %s failed: %w", path, err)
+			}
+			msgs = append(msgs, m)
+		}
+	}
+	return vals, nil
+}
+
+// GetPathData 根据字段路径获取单个字段，不存在时返回空ProtoValue，匹配多个字段时返回ErrDataNotSingularData
+func (p *ProtoMessage) GetPathData(path string) (ProtoValue, error) {
+	vals, err := p.GetPath(path)
+	if err != nil {
+		return ProtoValue{}, err
+	}
+	switch len(vals) {
+	case 0:
+		return ProtoValue{}, nil
+	case 1:
+		return vals[0], nil
+	}
+	return ProtoValue{}, ErrDataNotSingularData
+}
+
+// SetPath 将字段路径对应的字段设置为v，v的tag替换为路径最后一段的tag，路径格式与GetPath一致
+//
+// 最后一段不带下标时替换该tag的所有字段，带[i]时替换第i个字段（i等于字段个数时追加），带{key}时替换map中key对应的value。
+// 路径中间不存在的嵌套message和map entry会被创建，不带下标的repeated字段选择最后一个元素。
+// 修改后的嵌套message重新编码，字段的偏移量不再准确
+func (p *ProtoMessage) SetPath(path string, v ProtoValue) error {
+	segs, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	if _, err := v.appendTo(nil); err != nil {
+		return err
+	}
+	if err := p.setPath(segs, v); err != nil {
+		return fmt.Errorf("set path %s failed: %w", path, err)
+	}
+	return nil
+}
+
+func (p *ProtoMessage) setPath(segs []pathSegment, v ProtoValue) error {
+	// 复制字段，避免修改与其他ProtoMessage或延迟解析缓存共享的数据
+	p.Values = append(make([]ProtoValue, 0, len(p.Values)+1), p.Values...)
+	if p.index != nil {
+		// 修改完成后重新建立索引
+		p.index = nil
+		defer p.BuildIndex()
+	}
+	seg := segs[0]
+	if !seg.hasKey && len(segs) == 1 {
+		return p.setValue(seg, v)
+	}
+	var idx int
+	var child ProtoMessage
+	var err error
+	if seg.hasKey {
+		// map entry的value作为下一层
+		idx, child, err = p.mapEntry(seg)
+		segs = append([]pathSegment{{tag: valTag}}, segs[1:]...)
+	} else {
+		idx, child, err = p.nested(seg)
+		segs = segs[1:]
+	}
+	if err != nil {
+		return err
+	}
+	if err := child.setPath(segs, v); err != nil {
+		return err
+	}
+	return p.setNested(idx, child)
+}
+
+// setValue 按照seg替换或追加字段
+func (p *ProtoMessage) setValue(seg pathSegment, v ProtoValue) error {
+	v.tag = seg.tag
+	idxs := p.occurrences(seg.tag)
+	if seg.hasIndex {
+		i := seg.index
+		if i < 0 {
+			i += len(idxs)
+		}
+		switch {
+		case i >= 0 && i < len(idxs):
+			p.Values[idxs[i]] = v
+		case i == len(idxs) && len(idxs) > 0:
+			p.insert(idxs[len(idxs)-1]+1, v)
+		case i == len(idxs):
+			p.Values = append(p.Values, v)
+		default:
+			return fmt.Errorf("%w: %d[%d]", ErrPathNotFound, seg.tag, seg.index)
+		}
+		p.sort()
+		return nil
+	}
+	if len(idxs) == 0 {
+		p.Values = append(p.Values, v)
+		p.sort()
+		return nil
+	}
+	// 删除其余字段，新字段放在第一个字段的位置
+	p.Values[idxs[0]] = v
+	values := p.Values[:0]
+	for i := range p.Values {
+		if p.Values[i].tag != seg.tag || i == idxs[0] {
+			values = append(values, p.Values[i])
+		}
+	}
+	p.Values = values
+	p.sort()
+	return nil
+}
+
+// nested 返回seg对应的嵌套message及其下标，不存在时追加一个空的嵌套message
+func (p *ProtoMessage) nested(seg pathSegment) (int, ProtoMessage, error) {
+	idxs := p.occurrences(seg.tag)
+	i := len(idxs) - 1
+	if seg.hasIndex {
+		i = seg.index
+		if i < 0 {
+			i += len(idxs)
+		}
+		if i < 0 || i > len(idxs) {
+			return 0, ProtoMessage{}, fmt.Errorf("%w: %d[%d]", ErrPathNotFound, seg.tag, seg.index)
+		}
+	}
+	if i < 0 || i == len(idxs) {
+		v := ProtoValue{_type: protowire.BytesType, val: []byte{}, tag: seg.tag}
+		if len(idxs) > 0 {
+			p.insert(idxs[len(idxs)-1]+1, v)
+			return idxs[len(idxs)-1] + 1, ProtoMessage{}, nil
+		}
+		return p.appendField(v), ProtoMessage{}, nil
+	}
+	child, err := embeddedMessage(p.Values[idxs[i]])
+	return idxs[i], child, err
+}
+
+// mapEntry 返回seg.key对应的map entry及其下标，不存在时追加一个只包含key的map entry
+func (p *ProtoMessage) mapEntry(seg pathSegment) (int, ProtoMessage, error) {
+	found, err := seg.resolve(*p)
+	if err != nil {
+		return 0, ProtoMessage{}, err
+	}
+	if len(found) > 0 {
+		entry, err := embeddedMessage(p.Values[found[0]])
+		return found[0], entry, err
+	}
+	key, err := p.newMapKey(seg)
+	if err != nil {
+		return 0, ProtoMessage{}, err
+	}
+	i := p.appendField(ProtoValue{_type: protowire.BytesType, val: []byte{}, tag: seg.tag})
+	return i, ProtoMessage{Values: []ProtoValue{key}}, nil
+}
+
+// newMapKey 根据已有map entry中key的wire type编码seg.key，没有已有entry时数字按照varint、其他按照string编码
+func (p *ProtoMessage) newMapKey(seg pathSegment) (ProtoValue, error) {
+	typ := protowire.BytesType
+	if _, err := strconv.ParseInt(seg.key, 10, 64); err == nil {
+		typ = protowire.VarintType
+	}
+	for _, i := range p.occurrences(seg.tag) {
+		entry, err := embeddedMessage(p.Values[i])
+		if err != nil {
+			return ProtoValue{}, err
+		}
+		if key, ok := lastField(entry, keyTag); ok {
+			typ = key._type
+			break
+		}
+	}
+	key := ProtoValue{_type: typ, tag: keyTag}
+	var err error
+	switch typ {
+	case protowire.BytesType:
+		key.val = []byte(seg.key)
+	case protowire.VarintType:
+		key.val, err = parseMapKey(seg.key, 64)
+	case protowire.Fixed32Type:
+		var val uint64
+		val, err = parseMapKey(seg.key, 32)
+		key.val = uint32(val)
+	case protowire.Fixed64Type:
+		key.val, err = parseMapKey(seg.key, 64)
+	default:
+		err = ErrTypeMismatch
+	}
+	if err != nil {
+		return ProtoValue{}, fmt.Errorf("%w: invalid map key %q", ErrInvalidPath, seg.key)
+	}
+	return key, nil
+}
+
+// setNested 将修改后的嵌套message写回下标为idx的字段
+func (p *ProtoMessage) setNested(idx int, child ProtoMessage) error {
+	v := &p.Values[idx]
+	if v._type == protowire.StartGroupType {
+		child.sortType = v.val.(ProtoMessage).sortType
+		child.sort()
+		v.val = child
+		return nil
+	}
+	payload, err := Encode(child)
+	if err != nil {
+		return err
+	}
+	v.val = payload
+	if memo := v.memo(); memo != nil {
+		// 旧的解析结果已经失效
+		v.setMemo(&lazyMessage{index: memo.index})
+	}
+	return nil
+}
+
+func (p *ProtoMessage) insert(i int, v ProtoValue) {
+	p.Values = append(p.Values, ProtoValue{})
+	copy(p.Values[i+1:], p.Values[i:])
+	p.Values[i] = v
+}
+
+// appendField 在message末尾追加字段并返回其下标，排序的message中插入到相同tag的字段之后以保持有序
+func (p *ProtoMessage) appendField(v ProtoValue) int {
+	if p.sortType == NotSort {
+		p.Values = append(p.Values, v)
+		return len(p.Values) - 1
+	}
+	i := p.search(v.tag)
+	for i < len(p.Values) && p.Values[i].tag == v.tag {
+		i++
+	}
+	p.insert(i, v)
+	return i
+}
+
+// occurrences 返回tag在message中所有出现位置的下标
+func (p *ProtoMessage) occurrences(tag protowire.Number) []int {
+	if p.index != nil {
+		return p.index[tag]
+	}
+	var idxs []int
+	for i := range p.Values {
+		if p.Values[i].tag == tag {
+			idxs = append(idxs, i)
+		}
+	}
+	return idxs
+}
+
+// resolve 返回seg在m中匹配的字段下标，{key}匹配时返回map entry的下标
+func (s pathSegment) resolve(m ProtoMessage) ([]int, error) {
+	idxs := m.occurrences(s.tag)
+	switch {
+	case s.hasIndex:
+		i := s.index
+		if i < 0 {
+			i += len(idxs)
+		}
+		if i < 0 || i >= len(idxs) {
+			return nil, nil
+		}
+		return idxs[i : i+1], nil
+	case s.hasKey:
+		// key重复时以最后一个map entry为准
+		for j := len(idxs) - 1; j >= 0; j-- {
+			entry, err := embeddedMessage(m.Values[idxs[j]])
+			if err != nil {
+				return nil, err
+			}
+			if matchMapKey(entry, s.key) {
+				return idxs[j : j+1], nil
+			}
+		}
+		return nil, nil
+	}
+	return idxs, nil
+}
+
+// lookup 返回seg在m中匹配的字段，{key}匹配时返回map entry中的value
+func (s pathSegment) lookup(m ProtoMessage) ([]ProtoValue, error) {
+	idxs, err := s.resolve(m)
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]ProtoValue, 0, len(idxs))
+	for _, i := range idxs {
+		if !s.hasKey {
+			vals = append(vals, m.Values[i])
+			continue
+		}
+		entry, err := embeddedMessage(m.Values[i])
+		if err != nil {
+			return nil, err
+		}
+		if val, ok := lastField(entry, valTag); ok {
+			vals = append(vals, val)
+		}
+	}
+	return vals, nil
+}
+
+// embeddedMessage 将嵌套message或group解析为ProtoMessage
+func embeddedMessage(v ProtoValue) (ProtoMessage, error) {
+	switch v._type {
+	case protowire.BytesType:
+		return v.DecodeEmbeddedMsg(NotSort)
+	case protowire.StartGroupType:
+		return v.parseGroup()
+	}
+	return ProtoMessage{}, ErrTypeMismatch
+}
+
+// lastField 返回message中tag最后一次出现的字段
+func lastField(m ProtoMessage, tag protowire.Number) (ProtoValue, bool) {
+	for i := len(m.Values) - 1; i >= 0; i-- {
+		if m.Values[i].tag == tag {
+			return m.Values[i], true
+		}
+	}
+	return ProtoValue{}, false
+}
+
+// matchMapKey 判断map entry的key是否与路径中的key相同
+//
+// string key按照原文比较，整数key按照十进制比较（sint32/sint64需要使用ZigZag编码后的值），bool key为true/false
+func matchMapKey(entry ProtoMessage, key string) bool {
+	k, ok := lastField(entry, keyTag)
+	if !ok {
+		// 缺少key时为默认值
+		return key == "" || key == "0" || key == "false"
+	}
+	switch k._type {
+	case protowire.BytesType:
+		val, _ := k.parseLen()
+		return string(val) == key
+	case protowire.VarintType:
+		val, _ := k.parseVariant()
+		want, err := parseMapKey(key, 64)
+		return err == nil && want == val
+	case protowire.Fixed32Type:
+		val, _ := k.parseI32()
+		want, err := parseMapKey(key, 32)
+		return err == nil && uint32(want) == val
+	case protowire.Fixed64Type:
+		val, _ := k.parseI64()
+		want, err := parseMapKey(key, 64)
+		return err == nil && want == val
+	}
+	return false
+}
+
+// parseMapKey 将路径中的key解析为整数，负数按照补码表示
+func parseMapKey(key string, bitSize int) (uint64, error) {
+	switch key {
+	case "true":
+		return 1, nil
+	case "false":
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(key, 10, bitSize); err == nil {
+		if bitSize == 32 {
+			return uint64(uint32(n)), nil
+		}
+		return uint64(n), nil
+	}
+	return strconv.ParseUint(key, 10, bitSize)
+}
@@ -0,0 +1,213 @@
+package codec
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/KarKLi/protobuf-golang-codec/internal/proto3_test"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGetPath(t *testing.T) {
+	bin, err := proto.Marshal(testMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	m, err := Decode(bin, NotSort)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	v, err := m.GetPathData("14.3")
+	if err != nil {
+		t.Fatalf("can not get path 14.3, err: %+v", err)
+	}
+	realS, err := v.DecodeString()
+	if err != nil || realS != testMsg.M_14.S_3 {
+		t.Fatalf("parse result %s != real val %s, err: %+v", realS, testMsg.M_14.S_3, err)
+	}
+	v, err = m.GetPathData("14.5")
+	if err != nil || v.tag != 0 {
+		t.Fatalf("get missing path 14.5 returns %+v, err: %+v", v, err)
+	}
+	if _, err := m.GetPath("12.1"); err == nil {
+		t.Fatalf("get path through string field should fail")
+	}
+	for _, path := range []string{"", "14.", ".14", "14[", "14[a]", "14{a", "0", "14.x"} {
+		if _, err := m.GetPath(path); !errors.Is(err, ErrInvalidPath) {
+			t.Fatalf("path %q should be invalid, err: %+v", path, err)
+		}
+	}
+}
+
+func TestGetPathRepeatedAndMap(t *testing.T) {
+	bin, err := proto.Marshal(testUnpackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	m, err := Decode(bin, NotSort)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	vals, err := m.GetPath("17.3")
+	if err != nil {
+		t.Fatalf("can not get path 17.3, err: %+v", err)
+	}
+	if len(vals) != 2 {
+		t.Fatalf("expect 2 values at path 17.3, got %d", len(vals))
+	}
+	if _, err := m.GetPathData("17.3"); !errors.Is(err, ErrDataNotSingularData) {
+		t.Fatalf("get singular data at path 17.3 should fail, err: %+v", err)
+	}
+	for path, want := range map[string]uint64{
+		"17[1].2":  testUnpackedRepeatedMsg.M_17[1].F_2,
+		"17[-1].2": testUnpackedRepeatedMsg.M_17[4].F_2,
+	} {
+		v, err := m.GetPathData(path)
+		if err != nil {
+			t.Fatalf("can not get path %s, err: %+v", path, err)
+		}
+		realF, err := v.DecodeFixed64()
+		if err != nil || realF != want {
+			t.Fatalf("path %s parse result %d != real val %d, err: %+v", path, realF, want, err)
+		}
+	}
+	if vals, err := m.GetPath("17[5]"); err != nil || len(vals) != 0 {
+		t.Fatalf("index out of range should return nothing, got %d values, err: %+v", len(vals), err)
+	}
+	v, err := m.GetPathData("18{3}")
+	if err != nil {
+		t.Fatalf("can not get path 18{3}, err: %+v", err)
+	}
+	if realS, err := v.DecodeString(); err != nil || realS != testUnpackedRepeatedMsg.M_18[3] {
+		t.Fatalf("parse result %s != real val %s, err: %+v", realS, testUnpackedRepeatedMsg.M_18[3], err)
+	}
+	v, err = m.GetPathData("19{你好}")
+	if err != nil {
+		t.Fatalf("can not get path 19{你好}, err: %+v", err)
+	}
+	if realI, err := v.DecodeInt32(); err != nil || realI != testUnpackedRepeatedMsg.M_19["你好"] {
+		t.Fatalf("parse result %d != real val %d, err: %+v", realI, testUnpackedRepeatedMsg.M_19["你好"], err)
+	}
+	v, err = m.GetPathData("20{aa}.1")
+	if err != nil {
+		t.Fatalf("can not get path 20{aa}.1, err: %+v", err)
+	}
+	if realI, err := v.DecodeInt32(); err != nil || realI != testUnpackedRepeatedMsg.M_20["aa"].I_1 {
+		t.Fatalf("parse result %d != real val %d, err: %+v", realI, testUnpackedRepeatedMsg.M_20["aa"].I_1, err)
+	}
+	if vals, err := m.GetPath("20{b}"); err != nil || len(vals) != 0 {
+		t.Fatalf("missing map key should return nothing, got %d values, err: %+v", len(vals), err)
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	bin, err := proto.Marshal(testUnpackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	m, err := DecodeWithOptions(bin, DecodeOptions{SortType: Asc, Lazy: true})
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	// 缓存嵌套message的解析结果，修改后应当失效
+	if _, err := m.GetPath("17.3"); err != nil {
+		t.Fatalf("can not get path 17.3, err: %+v", err)
+	}
+	set := func(path string, b *MessageBuilder) {
+		v, err := b.Value()
+		if err != nil {
+			t.Fatalf("build value for path %s failed, err: %+v", path, err)
+		}
+		if err := m.SetPath(path, v); err != nil {
+			t.Fatalf("set path %s failed, err: %+v", path, err)
+		}
+	}
+	set("17[1].3", NewBuilder().String(3, "bb"))
+	set("17[5].1", NewBuilder().Int32(1, -7))
+	set("18{3}", NewBuilder().String(2, "再见"))
+	set("18{-4}", NewBuilder().String(2, "new"))
+	set("19{b}", NewBuilder().Int32(2, 9))
+	set("20{aa}.3", NewBuilder().String(3, "cc"))
+	set("20{new}.4", NewBuilder().Fixed32(4, 42))
+	set("15", NewBuilder().String(15, "only"))
+	if v, _ := NewBuilder().Int32(1, 1).Value(); !errors.Is(m.SetPath("17[9].1", v), ErrPathNotFound) {
+		t.Fatalf("set path out of range should fail")
+	}
+	if v, _ := NewBuilder().Int32(1, 1).Value(); m.SetPath("12.1", v) == nil {
+		t.Fatalf("set path through non message field should fail")
+	}
+	v, err := m.GetPathData("17[1].3")
+	if err != nil {
+		t.Fatalf("can not get path 17[1].3, err: %+v", err)
+	}
+	if realS, err := v.DecodeString(); err != nil || realS != "bb" {
+		t.Fatalf("parse result %s != real val bb, err: %+v", realS, err)
+	}
+
+	out, err := Encode(m)
+	if err != nil {
+		t.Fatalf("encode modified message failed, err: %+v", err)
+	}
+	got := &proto3_test.RepeatedMsgWithUnpacked{}
+	if err := proto.Unmarshal(out, got); err != nil {
+		t.Fatalf("unmarshal modified message failed, err: %+v", err)
+	}
+	want := proto.Clone(testUnpackedRepeatedMsg).(*proto3_test.RepeatedMsgWithUnpacked)
+	want.M_17[1].S_3 = "bb"
+	want.M_17 = append(want.M_17, &proto3_test.Embeeded{I_1: -7})
+	want.M_18[3] = "再见"
+	want.M_18[-4] = "new"
+	want.M_19["b"] = 9
+	want.M_20["aa"].S_3 = "cc"
+	want.M_20["new"] = &proto3_test.Embeeded{F_4: 42}
+	want.S_15 = []string{"only"}
+	if !proto.Equal(got, want) {
+		t.Fatalf("modified message %v != expected %v", got, want)
+	}
+}
+
+func TestSetPathSorted(t *testing.T) {
+	bin, err := NewBuilder().Int32(1, 1).Int32(10, 10).Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	for _, sortType := range []MessageSortType{Asc, Desc} {
+		m, err := Decode(bin, sortType)
+		if err != nil {
+			t.Fatalf("decode test proto message failed, err: %+v", err)
+		}
+		v, err := NewBuilder().Int32(1, 5).Value()
+		if err != nil {
+			t.Fatalf("build value failed, err: %+v", err)
+		}
+		// 新增的嵌套message和map entry插入到排序后的位置
+		if err := m.SetPath("5.1", v); err != nil {
+			t.Fatalf("set path 5.1 failed, err: %+v", err)
+		}
+		if err := m.SetPath("7{k}.1", v); err != nil {
+			t.Fatalf("set path 7{k}.1 failed, err: %+v", err)
+		}
+		if err := m.SetPath("7{j}.1", v); err != nil {
+			t.Fatalf("set path 7{j}.1 failed, err: %+v", err)
+		}
+		for i := 1; i < len(m.Values); i++ {
+			prev, cur := m.Values[i-1].tag, m.Values[i].tag
+			if (sortType == Asc && prev > cur) || (sortType == Desc && prev < cur) {
+				t.Fatalf("sort type %d values not sorted after SetPath: tag %d before tag %d", sortType, prev, cur)
+			}
+		}
+		if v5, err := m.GetData(5); err != nil || v5.tag != 5 {
+			t.Fatalf("can not get tag=5's data, got %+v, err: %+v", v5, err)
+		}
+		if idxs, err := m.GetRepeatedData(7); err != nil || len(idxs) != 2 {
+			t.Fatalf("can not get tag=7's data, got %v, err: %+v", idxs, err)
+		}
+		got, err := m.GetPathData("5.1")
+		if err != nil {
+			t.Fatalf("can not get path 5.1, err: %+v", err)
+		}
+		if realI, err := got.DecodeInt32(); err != nil || realI != 5 {
+			t.Fatalf("parse result %d != real val 5, err: %+v", realI, err)
+		}
+	}
+}
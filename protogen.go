@@ -0,0 +1,351 @@
+package codec
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// protoMessageDef 待输出的message定义
+type protoMessageDef struct {
+	name   string
+	nested []*protoMessageDef
+	fields []protoFieldDef
+}
+
+// protoFieldDef 待输出的字段定义
+type protoFieldDef struct {
+	// typ 字段类型，例如int32、map<int32, string>或嵌套message名
+	typ      string
+	name     string
+	number   int32
+	repeated bool
+	// packed、unpacked 可以packed的repeated字段是否按照packed编码，proto3输出[packed = false]，proto2输出[packed = true]
+	packed   bool
+	unpacked bool
+	// group 不为nil时按照proto2的group语法输出，typ和name不使用
+	group   *protoMessageDef
+	comment string
+}
+
+// WriteInferredProto 将Infer推断得到的message结构输出为proto3格式的.proto文件
+//
+// 嵌套message命名为Field{tag}，字段命名为field_{tag}，enum按照int32输出，pkg为空时不输出package；
+// 包含group时输出为proto2格式，group按照group语法输出，字段名为field{tag}
+func WriteInferredProto(w io.Writer, pkg, name string, m *InferredMessage) error {
+	return writeProtoFile(w, pkg, []*protoMessageDef{inferredMessageDef(name, m)})
+}
+
+// WriteDescriptorProto 将MessageDescriptor及其引用的所有message输出为proto3格式的.proto文件
+//
+// enum按照int32输出；包含group时输出为proto2格式，group按照group语法输出，
+// group的message不是字段所在message的嵌套message或者名字与字段名不对应时无法使用group语法，返回错误
+func WriteDescriptorProto(w io.Writer, pkg string, md protoreflect.MessageDescriptor) error {
+	// 收集所有引用到的顶层message
+	roots := make(map[protoreflect.FullName]protoreflect.MessageDescriptor)
+	visited := make(map[protoreflect.FullName]bool)
+	var collect func(md protoreflect.MessageDescriptor)
+	collect = func(md protoreflect.MessageDescriptor) {
+		if visited[md.FullName()] {
+			return
+		}
+		visited[md.FullName()] = true
+		root := md
+		for {
+			parent, ok := root.Parent().(protoreflect.MessageDescriptor)
+			if !ok {
+				break
+			}
+			root = parent
+		}
+		roots[root.FullName()] = root
+		fields := md.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			if sub := fields.Get(i).Message(); sub != nil {
+				collect(sub)
+			}
+		}
+		nested := md.Messages()
+		for i := 0; i < nested.Len(); i++ {
+			collect(nested.Get(i))
+		}
+	}
+	collect(md)
+
+	names := make([]string, 0, len(roots))
+	for name := range roots {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	// 指定的message最先输出
+	first, err := descriptorMessageDef(roots[rootOf(md).FullName()])
+	if err != nil {
+		return err
+	}
+	defs := make([]*protoMessageDef, 0, len(roots))
+	defs = append(defs, first)
+	for _, name := range names {
+		root := roots[protoreflect.FullName(name)]
+		if root.FullName() == rootOf(md).FullName() {
+			continue
+		}
+		def, err := descriptorMessageDef(root)
+		if err != nil {
+			return err
+		}
+		defs = append(defs, def)
+	}
+	return writeProtoFile(w, pkg, defs)
+}
+
+func rootOf(md protoreflect.MessageDescriptor) protoreflect.MessageDescriptor {
+	for {
+		parent, ok := md.Parent().(protoreflect.MessageDescriptor)
+		if !ok {
+			return md
+		}
+		md = parent
+	}
+}
+
+func inferredMessageDef(name string, m *InferredMessage) *protoMessageDef {
+	def := &protoMessageDef{name: name}
+	for _, f := range m.Fields {
+		field := protoFieldDef{
+			name:     fmt.Sprintf("field_%d", f.Number),
+			number:   int32(f.Number),
+			repeated: f.Repeated,
+			comment:  inferredComment(f),
+		}
+		switch {
+		case f.Map && inferredMapDef(def, f, &field):
+			field.repeated = false
+		case f.Message != nil && f.Kind == protoreflect.GroupKind:
+			field.group = inferredMessageDef(fmt.Sprintf("Field%d", f.Number), f.Message)
+		case f.Message != nil:
+			sub := inferredMessageDef(fmt.Sprintf("Field%d", f.Number), f.Message)
+			def.nested = append(def.nested, sub)
+			field.typ = sub.name
+		default:
+			field.typ = scalarTypeName(f.Kind)
+			field.packed = f.Repeated && f.Packed && isPackableKind(f.Kind)
+			field.unpacked = f.Repeated && !f.Packed && isPackableKind(f.Kind)
+		}
+		def.fields = append(def.fields, field)
+	}
+	return def
+}
+
+// inferredMapDef 输出map字段，key类型不合法时返回false，按照repeated message输出
+func inferredMapDef(def *protoMessageDef, f *InferredField, field *protoFieldDef) bool {
+	keyType := "int32"
+	if key := f.Message.Field(keyTag); key != nil {
+		if !isMapKeyKind(key.Kind) {
+			return false
+		}
+		keyType = scalarTypeName(key.Kind)
+	}
+	valType := "int32"
+	if val := f.Message.Field(valTag); val != nil {
+		if val.Message != nil {
+			sub := inferredMessageDef(fmt.Sprintf("Field%dValue", f.Number), val.Message)
+			def.nested = append(def.nested, sub)
+			valType = sub.name
+		} else {
+			valType = scalarTypeName(val.Kind)
+		}
+	}
+	field.typ = fmt.Sprintf("map<%s, %s>", keyType, valType)
+	return true
+}
+
+func inferredComment(f *InferredField) string {
+	comment := fmt.Sprintf("confidence %.2f", f.Confidence)
+	if len(f.Alternatives) > 0 {
+		alts := make([]string, 0, len(f.Alternatives))
+		for _, kind := range f.Alternatives {
+			alts = append(alts, kind.String())
+		}
+		comment += ", alternatives: " + strings.Join(alts, ", ")
+	}
+	return comment
+}
+
+func descriptorMessageDef(md protoreflect.MessageDescriptor) (*protoMessageDef, error) {
+	def := &protoMessageDef{name: string(md.Name())}
+	fields := md.Fields()
+	// groups 按照group语法在字段中输出的嵌套message
+	groups := make(map[protoreflect.FullName]*protoMessageDef)
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Kind() != protoreflect.GroupKind {
+			continue
+		}
+		group := fd.Message()
+		if group.Parent().FullName() != md.FullName() || strings.ToLower(string(group.Name())) != string(fd.Name()) {
+			return nil, fmt.Errorf("can not write group field %s with proto2 group syntax", fd.FullName())
+		}
+		if groups[group.FullName()] != nil {
+			return nil, fmt.Errorf("can not write group field %s with proto2 group syntax", fd.FullName())
+		}
+		sub, err := descriptorMessageDef(group)
+		if err != nil {
+			return nil, err
+		}
+		groups[group.FullName()] = sub
+	}
+	nested := md.Messages()
+	for i := 0; i < nested.Len(); i++ {
+		if nested.Get(i).IsMapEntry() || groups[nested.Get(i).FullName()] != nil {
+			continue
+		}
+		sub, err := descriptorMessageDef(nested.Get(i))
+		if err != nil {
+			return nil, err
+		}
+		def.nested = append(def.nested, sub)
+	}
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		field := protoFieldDef{
+			typ:      descriptorTypeName(fd),
+			name:     string(fd.Name()),
+			number:   int32(fd.Number()),
+			repeated: fd.IsList(),
+			packed:   fd.IsList() && fd.IsPacked() && isPackableKind(fd.Kind()),
+			unpacked: fd.IsList() && !fd.IsPacked() && isPackableKind(fd.Kind()),
+		}
+		if fd.IsMap() {
+			field.typ = fmt.Sprintf("map<%s, %s>", descriptorTypeName(fd.MapKey()), descriptorTypeName(fd.MapValue()))
+		}
+		if fd.Kind() == protoreflect.GroupKind {
+			field.group = groups[fd.Message().FullName()]
+		}
+		def.fields = append(def.fields, field)
+	}
+	return def, nil
+}
+
+// descriptorTypeName 返回字段类型名，message类型返回去掉package前缀的全名
+func descriptorTypeName(fd protoreflect.FieldDescriptor) string {
+	md := fd.Message()
+	if md == nil {
+		return scalarTypeName(fd.Kind())
+	}
+	name := string(md.FullName())
+	if pkg := string(md.ParentFile().Package()); pkg != "" {
+		name = strings.TrimPrefix(name, pkg+".")
+	}
+	return name
+}
+
+func scalarTypeName(kind protoreflect.Kind) string {
+	if kind == protoreflect.EnumKind {
+		return "int32"
+	}
+	return kind.String()
+}
+
+// isMapKeyKind 判断字段类型是否可以作为map的key
+func isMapKeyKind(kind protoreflect.Kind) bool {
+	switch kind {
+	case protoreflect.FloatKind, protoreflect.DoubleKind, protoreflect.BytesKind,
+		protoreflect.MessageKind, protoreflect.GroupKind:
+		return false
+	}
+	return true
+}
+
+func writeProtoFile(w io.Writer, pkg string, defs []*protoMessageDef) error {
+	// proto3没有group语法，包含group时输出为proto2格式，保证与数据的编码方式一致
+	proto2 := false
+	for _, def := range defs {
+		proto2 = proto2 || hasGroup(def)
+	}
+	var sb strings.Builder
+	if proto2 {
+		sb.WriteString("syntax = \"proto2\";\n")
+	} else {
+		sb.WriteString("syntax = \"proto3\";\n")
+	}
+	if pkg != "" {
+		fmt.Fprintf(&sb, "\npackage %s;\n", pkg)
+	}
+	for _, def := range defs {
+		sb.WriteString("\n")
+		writeMessageDef(&sb, def, 0, proto2)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// hasGroup 判断def及其嵌套message中是否包含group字段
+func hasGroup(def *protoMessageDef) bool {
+	for _, sub := range def.nested {
+		if hasGroup(sub) {
+			return true
+		}
+	}
+	for _, field := range def.fields {
+		if field.group != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func writeMessageDef(sb *strings.Builder, def *protoMessageDef, depth int, proto2 bool) {
+	indent := strings.Repeat("  ", depth)
+	fmt.Fprintf(sb, "%smessage %s {\n", indent, def.name)
+	writeMessageBody(sb, def, depth, proto2)
+	fmt.Fprintf(sb, "%s}\n", indent)
+}
+
+// writeMessageBody 输出message或group的嵌套message和字段
+func writeMessageBody(sb *strings.Builder, def *protoMessageDef, depth int, proto2 bool) {
+	indent := strings.Repeat("  ", depth)
+	for i, sub := range def.nested {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		writeMessageDef(sb, sub, depth+1, proto2)
+	}
+	if len(def.nested) > 0 && len(def.fields) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, field := range def.fields {
+		sb.WriteString(indent + "  ")
+		switch {
+		case field.repeated:
+			sb.WriteString("repeated ")
+		case proto2 && !strings.HasPrefix(field.typ, "map<"):
+			sb.WriteString("optional ")
+		}
+		if field.group != nil {
+			// group语法的字段名为group名的小写形式
+			fmt.Fprintf(sb, "group %s = %d {", field.group.name, field.number)
+			if field.comment != "" {
+				sb.WriteString(" // " + field.comment)
+			}
+			sb.WriteString("\n")
+			writeMessageBody(sb, field.group, depth+1, proto2)
+			fmt.Fprintf(sb, "%s  }\n", indent)
+			continue
+		}
+		fmt.Fprintf(sb, "%s %s = %d", field.typ, field.name, field.number)
+		switch {
+		case proto2 && field.packed:
+			sb.WriteString(" [packed = true]")
+		case !proto2 && field.unpacked:
+			sb.WriteString(" [packed = false]")
+		}
+		sb.WriteString(";")
+		if field.comment != "" {
+			sb.WriteString(" // " + field.comment)
+		}
+		sb.WriteString("\n")
+	}
+}
@@ -0,0 +1,180 @@
+package codec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/KarKLi/protobuf-golang-codec/internal/proto3_test"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestWriteInferredProto(t *testing.T) {
+	samples := decodeSamples(t,
+		&proto3_test.RepeatedMsgWithUnpacked{
+			I_1:  []int32{-4, 5},
+			S_15: []string{"a", "b"},
+			M_17: []*proto3_test.Embeeded{{I_1: 7, S_3: "x"}, {S_3: "y"}},
+			M_19: map[string]int32{"a": 100, "b": -200},
+		},
+	)
+	var sb strings.Builder
+	if err := WriteInferredProto(&sb, "test.inferred", "Msg", Infer(samples...)); err != nil {
+		t.Fatalf("can not write inferred proto, err: %+v", err)
+	}
+	expect := `syntax = "proto3";
+
+package test.inferred;
+
+message Msg {
+  message Field17 {
+    int32 field_1 = 1; // confidence 0.50, alternatives: sint32, uint32, int64, enum
+    string field_3 = 3; // confidence 0.90, alternatives: bytes
+  }
+
+  repeated int32 field_1 = 1 [packed = false]; // confidence 0.90, alternatives: int64, uint64
+  repeated string field_15 = 15; // confidence 0.90, alternatives: bytes
+  repeated Field17 field_17 = 17; // confidence 0.80, alternatives: bytes
+  map<string, int32> field_19 = 19; // confidence 0.80, alternatives: bytes
+}
+`
+	if sb.String() != expect {
+		t.Fatalf("write result:\n%s\n!= expected:\n%s", sb.String(), expect)
+	}
+}
+
+func TestWriteDescriptorProto(t *testing.T) {
+	var sb strings.Builder
+	if err := WriteDescriptorProto(&sb, "", testUnpackedRepeatedMsg.ProtoReflect().Descriptor()); err != nil {
+		t.Fatalf("can not write descriptor proto, err: %+v", err)
+	}
+	result := sb.String()
+	for _, line := range []string{
+		"message RepeatedMsgWithUnpacked {\n",
+		"  repeated int32 i_1 = 1 [packed = false];\n",
+		"  repeated int32 e_8 = 8 [packed = false];\n",
+		"  repeated string s_15 = 15;\n",
+		"  repeated Embeeded m_17 = 17;\n",
+		"  map<string, Embeeded> m_20 = 20;\n",
+		"message Embeeded {\n",
+		"  fixed64 f_2 = 2;\n",
+	} {
+		if !strings.Contains(result, line) {
+			t.Fatalf("write result:\n%s\nmissing line %q", result, line)
+		}
+	}
+	if strings.Contains(result, "package") || strings.Contains(result, "MEntry") {
+		t.Fatalf("write result:\n%s\ncontains unexpected package or map entry", result)
+	}
+}
+
+func TestWriteInferredProtoGroup(t *testing.T) {
+	bin, err := NewBuilder().
+		Int32(1, 5).
+		Group(4, NewBuilder().String(1, "x").Int32(2, 3)).
+		Group(4, NewBuilder().String(1, "y")).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	m, err := Decode(bin, NotSort)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	var sb strings.Builder
+	if err := WriteInferredProto(&sb, "", "Msg", Infer(m)); err != nil {
+		t.Fatalf("can not write inferred proto, err: %+v", err)
+	}
+	// proto3没有group语法，按照proto2输出
+	expect := `syntax = "proto2";
+
+message Msg {
+  optional int32 field_1 = 1; // confidence 0.50, alternatives: sint32, uint32, int64, enum
+  repeated group Field4 = 4 { // confidence 1.00
+    optional string field_1 = 1; // confidence 0.90, alternatives: bytes
+    optional int32 field_2 = 2; // confidence 0.50, alternatives: sint32, uint32, int64, enum
+  }
+}
+`
+	if sb.String() != expect {
+		t.Fatalf("write result:\n%s\n!= expected:\n%s", sb.String(), expect)
+	}
+}
+
+func TestWriteDescriptorProtoGroup(t *testing.T) {
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("group.proto"),
+		Package: proto.String("group"),
+		Syntax:  proto.String("proto2"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Outer"),
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Item"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("s"), Number: proto.Int32(1), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+				},
+			}},
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("item"), Number: proto.Int32(1), Label: repeated, Type: descriptorpb.FieldDescriptorProto_TYPE_GROUP.Enum(), TypeName: proto.String(".group.Outer.Item")},
+				{Name: proto.String("i"), Number: proto.Int32(2), Label: repeated, Type: descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(), Options: &descriptorpb.FieldOptions{Packed: proto.Bool(true)}},
+				{Name: proto.String("u"), Number: proto.Int32(3), Label: repeated, Type: descriptorpb.FieldDescriptorProto_TYPE_UINT32.Enum()},
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, nil)
+	if err != nil {
+		t.Fatalf("can not build file descriptor, err: %+v", err)
+	}
+	var sb strings.Builder
+	if err := WriteDescriptorProto(&sb, "group", fd.Messages().Get(0)); err != nil {
+		t.Fatalf("can not write descriptor proto, err: %+v", err)
+	}
+	expect := `syntax = "proto2";
+
+package group;
+
+message Outer {
+  repeated group Item = 1 {
+    optional string s = 1;
+  }
+  repeated int32 i = 2 [packed = true];
+  repeated uint32 u = 3;
+}
+`
+	if sb.String() != expect {
+		t.Fatalf("write result:\n%s\n!= expected:\n%s", sb.String(), expect)
+	}
+
+	// editions中DELIMITED编码的字段引用的message不是嵌套message时无法使用group语法
+	fdp = &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("delimited.proto"),
+		Package: proto.String("delimited"),
+		Syntax:  proto.String("editions"),
+		Edition: descriptorpb.Edition_EDITION_2023.Enum(),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Inner"),
+		}, {
+			Name: proto.String("Outer"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("inner"),
+				Number:   proto.Int32(1),
+				Label:    optional,
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".delimited.Inner"),
+				Options: &descriptorpb.FieldOptions{
+					Features: &descriptorpb.FeatureSet{MessageEncoding: descriptorpb.FeatureSet_DELIMITED.Enum()},
+				},
+			}},
+		}},
+	}
+	fd, err = protodesc.NewFile(fdp, nil)
+	if err != nil {
+		t.Fatalf("can not build file descriptor, err: %+v", err)
+	}
+	if err := WriteDescriptorProto(&sb, "", fd.Messages().Get(1)); err == nil {
+		t.Fatalf("write delimited field with top level message should fail")
+	}
+}
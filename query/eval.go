@@ -0,0 +1,346 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	codec "github.com/KarKLi/protobuf-golang-codec"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// node 查询语法树的节点，对每个输入产生零个或多个结果
+type node interface {
+	eval(in interface{}) ([]interface{}, error)
+}
+
+type pipeNode struct {
+	left, right node
+}
+
+func (n *pipeNode) eval(in interface{}) ([]interface{}, error) {
+	left, err := n.left.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []interface{}
+	for _, v := range left {
+		right, err := n.right.eval(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, right...)
+	}
+	return out, nil
+}
+
+type commaNode struct {
+	items []node
+}
+
+func (n *commaNode) eval(in interface{}) ([]interface{}, error) {
+	var out []interface{}
+	for _, item := range n.items {
+		vals, err := item.eval(in)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, vals...)
+	}
+	return out, nil
+}
+
+// pathNode 字段路径，path为空时表示当前输入
+type pathNode struct {
+	path string
+}
+
+func (n *pathNode) eval(in interface{}) ([]interface{}, error) {
+	if n.path == "" {
+		return []interface{}{in}, nil
+	}
+	m, err := message(in)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := m.GetPath(n.path)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, len(vals))
+	for i := range vals {
+		out[i] = vals[i]
+	}
+	return out, nil
+}
+
+// message 将输入转换为可以按照路径访问的ProtoMessage
+func message(in interface{}) (codec.ProtoMessage, error) {
+	switch in := in.(type) {
+	case codec.ProtoMessage:
+		return in, nil
+	case codec.ProtoValue:
+		switch in.Type() {
+		case protowire.BytesType:
+			return in.DecodeEmbeddedMsg(codec.NotSort)
+		case protowire.StartGroupType:
+			return in.DecodeGroup()
+		}
+		return codec.ProtoMessage{}, fmt.Errorf("can not access fields of tag %d with wire type %d", in.Tag(), in.Type())
+	}
+	return codec.ProtoMessage{}, fmt.Errorf("can not access fields of %T", in)
+}
+
+// castNode 按照字段类型解析as左侧的结果
+type castNode struct {
+	inner node
+	kind  protoreflect.Kind
+}
+
+func (n *castNode) eval(in interface{}) ([]interface{}, error) {
+	vals, err := n.inner.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, 0, len(vals))
+	for _, v := range vals {
+		switch v := v.(type) {
+		case codec.ProtoValue:
+			val, err := v.DecodeKind(n.kind)
+			if err != nil {
+				return nil, fmt.Errorf("interpret tag %d as %s failed: %w", v.Tag(), n.kind, err)
+			}
+			out = append(out, val)
+		case codec.ProtoMessage:
+			if n.kind != protoreflect.MessageKind && n.kind != protoreflect.GroupKind {
+				return nil, fmt.Errorf("can not interpret message as %s", n.kind)
+			}
+			out = append(out, v)
+		default:
+			return nil, fmt.Errorf("can not interpret %T as %s", v, n.kind)
+		}
+	}
+	return out, nil
+}
+
+type literalNode struct {
+	val interface{}
+}
+
+func (n *literalNode) eval(interface{}) ([]interface{}, error) {
+	return []interface{}{n.val}, nil
+}
+
+// selectNode pred的结果中存在真值时输出当前输入
+type selectNode struct {
+	pred node
+}
+
+func (n *selectNode) eval(in interface{}) ([]interface{}, error) {
+	vals, err := n.pred.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vals {
+		if truthy(v) {
+			return []interface{}{in}, nil
+		}
+	}
+	return nil, nil
+}
+
+// logicNode and/or，对两侧结果的每种组合分别求值
+type logicNode struct {
+	and         bool
+	left, right node
+}
+
+func (n *logicNode) eval(in interface{}) ([]interface{}, error) {
+	left, err := n.left.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	var out []interface{}
+	for _, l := range left {
+		// 短路求值
+		if truthy(l) != n.and {
+			out = append(out, !n.and)
+			continue
+		}
+		right, err := n.right.eval(in)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range right {
+			out = append(out, truthy(r))
+		}
+	}
+	return out, nil
+}
+
+type notNode struct {
+	inner node
+}
+
+func (n *notNode) eval(in interface{}) ([]interface{}, error) {
+	vals, err := n.inner.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, len(vals))
+	for i, v := range vals {
+		out[i] = !truthy(v)
+	}
+	return out, nil
+}
+
+// compareNode 比较两侧结果的每种组合
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) eval(in interface{}) ([]interface{}, error) {
+	left, err := n.left.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(in)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, 0, len(left)*len(right))
+	for _, l := range left {
+		for _, r := range right {
+			ok, err := compare(n.op, l, r)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, ok)
+		}
+	}
+	return out, nil
+}
+
+// truthy 除false以外的结果均为真值
+func truthy(v interface{}) bool {
+	b, ok := v.(bool)
+	return !ok || b
+}
+
+// compare 比较两个结果，数字之间按数值比较，字符串和bytes按字节比较，bool只支持==和!=，其他类型之间只有!=成立
+func compare(op string, a, b interface{}) (bool, error) {
+	a, b = scalar(a), scalar(b)
+	var c int
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return mismatch(op, a, b)
+		}
+		switch {
+		case x < y:
+			c = -1
+		case x > y:
+			c = 1
+		}
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return mismatch(op, a, b)
+		}
+		if op != "==" && op != "!=" {
+			return false, fmt.Errorf("can not compare bool with %s", op)
+		}
+		if x != y {
+			c = 1
+		}
+	default:
+		fx, okx := number(a)
+		fy, oky := number(b)
+		if !okx || !oky {
+			return mismatch(op, a, b)
+		}
+		if fx == nil || fy == nil {
+			// NaN与任何值都不相等
+			return op == "!=", nil
+		}
+		c = fx.Cmp(fy)
+	}
+	switch op {
+	case "==":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	}
+	return c >= 0, nil
+}
+
+func mismatch(op string, a, b interface{}) (bool, error) {
+	switch op {
+	case "==":
+		return false, nil
+	case "!=":
+		return true, nil
+	}
+	return false, fmt.Errorf("can not compare %T with %T", a, b)
+}
+
+// scalar 将未指定类型的字段按照wire type转换为默认类型：varint为uint64，fixed32为uint32，fixed64为uint64，BytesType为string
+func scalar(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case codec.ProtoValue:
+		var val interface{}
+		var err error
+		switch v.Type() {
+		case protowire.VarintType:
+			val, err = v.DecodeUint64()
+		case protowire.Fixed32Type:
+			val, err = v.DecodeFixed32()
+		case protowire.Fixed64Type:
+			val, err = v.DecodeFixed64()
+		case protowire.BytesType:
+			val, err = v.DecodeString()
+		}
+		if err == nil && val != nil {
+			return val
+		}
+	}
+	return v
+}
+
+// number 将数字转换为big.Float以便精确比较整数和浮点数，NaN返回nil
+func number(v interface{}) (*big.Float, bool) {
+	f := new(big.Float).SetPrec(128)
+	switch v := v.(type) {
+	case int32:
+		return f.SetInt64(int64(v)), true
+	case int64:
+		return f.SetInt64(v), true
+	case uint32:
+		return f.SetUint64(uint64(v)), true
+	case uint64:
+		return f.SetUint64(v), true
+	case float32:
+		return float(f, float64(v)), true
+	case float64:
+		return float(f, v), true
+	}
+	return nil, false
+}
+
+func float(f *big.Float, v float64) *big.Float {
+	if math.IsNaN(v) {
+		return nil
+	}
+	return f.SetFloat64(v)
+}
@@ -0,0 +1,340 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	codec "github.com/KarKLi/protobuf-golang-codec"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// kinds as后可用的类型名，与protoreflect.Kind.String()一致
+var kinds = func() map[string]protoreflect.Kind {
+	m := make(map[string]protoreflect.Kind)
+	for k := protoreflect.DoubleKind; k <= protoreflect.Sint64Kind; k++ {
+		m[k.String()] = k
+	}
+	return m
+}()
+
+// compareOps 比较运算符，较长的运算符在前
+var compareOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// parser 递归下降解析查询语句
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) parse() (node, error) {
+	n, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:p.pos+1])
+	}
+	return n, nil
+}
+
+func (p *parser) parsePipe() (node, error) {
+	left, err := p.parseComma()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("|") {
+		right, err := p.parseComma()
+		if err != nil {
+			return nil, err
+		}
+		left = &pipeNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseComma() (node, error) {
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	items := []node{n}
+	for p.accept(",") {
+		if n, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+		items = append(items, n)
+	}
+	if len(items) == 1 {
+		return items[0], nil
+	}
+	return &commaNode{items: items}, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.acceptKeyword("not") {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{inner: inner}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parseCast(false)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range compareOps {
+		if p.accept(op) {
+			right, err := p.parseCast(true)
+			if err != nil {
+				return nil, err
+			}
+			return &compareNode{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+// parseCast 解析带有可选as TYPE的操作数，rhs为true时数字解析为字面量
+func (p *parser) parseCast(rhs bool) (node, error) {
+	n, err := p.parsePrimary(rhs)
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("as") {
+		p.skipSpace()
+		start := p.pos
+		name := p.ident()
+		kind, ok := kinds[name]
+		if !ok {
+			p.pos = start
+			return nil, p.errorf("unknown type %q", name)
+		}
+		n = &castNode{inner: n, kind: kind}
+	}
+	return n, nil
+}
+
+func (p *parser) parsePrimary(rhs bool) (node, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("unexpected end of query")
+	}
+	c := p.src[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		n, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("expect )")
+		}
+		return n, nil
+	case c == '"':
+		return p.parseString()
+	case c == '.':
+		return p.parsePath()
+	case c == '-' || (rhs && isDigit(c)):
+		return p.parseNumber()
+	case isDigit(c):
+		return p.parsePath()
+	}
+	start := p.pos
+	switch name := p.ident(); name {
+	case "true", "false":
+		return &literalNode{val: name == "true"}, nil
+	case "select":
+		if !p.accept("(") {
+			return nil, p.errorf("expect ( after select")
+		}
+		pred, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("expect )")
+		}
+		return &selectNode{pred: pred}, nil
+	case "":
+		return nil, p.errorf("unexpected %q", p.src[p.pos:p.pos+1])
+	default:
+		p.pos = start
+		return nil, p.errorf("unknown identifier %q", name)
+	}
+}
+
+// parsePath 解析字段路径，转换为codec.ProtoMessage.GetPath的格式
+func (p *parser) parsePath() (node, error) {
+	start := p.pos
+	if p.src[p.pos] == '.' {
+		p.pos++
+		if p.pos >= len(p.src) || !isDigit(p.src[p.pos]) {
+			return &pathNode{}, nil
+		}
+	}
+	var sb strings.Builder
+	for {
+		begin := p.pos
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+		}
+		if begin == p.pos {
+			return nil, p.errorf("expect tag")
+		}
+		sb.WriteString(p.src[begin:p.pos])
+		if p.pos < len(p.src) && (p.src[p.pos] == '[' || p.src[p.pos] == '{') {
+			end := "]"
+			if p.src[p.pos] == '{' {
+				end = "}"
+			}
+			j := strings.Index(p.src[p.pos:], end)
+			if j < 0 {
+				return nil, p.errorf("expect %s", end)
+			}
+			// []表示所有元素，与不带下标相同
+			if j > 1 || end == "}" {
+				sb.WriteString(p.src[p.pos : p.pos+j+1])
+			}
+			p.pos += j + 1
+		}
+		if p.pos+1 < len(p.src) && p.src[p.pos] == '.' && isDigit(p.src[p.pos+1]) {
+			sb.WriteByte('.')
+			p.pos++
+			continue
+		}
+		break
+	}
+	path := sb.String()
+	// 借助GetPath检查路径格式
+	if _, err := (&codec.ProtoMessage{}).GetPath(path); errors.Is(err, codec.ErrInvalidPath) {
+		src := p.src[start:p.pos]
+		p.pos = start
+		return nil, p.errorf("invalid path %q", src)
+	}
+	return &pathNode{path: path}, nil
+}
+
+func (p *parser) parseString() (node, error) {
+	start := p.pos
+	for i := p.pos + 1; i < len(p.src); i++ {
+		switch p.src[i] {
+		case '\\':
+			i++
+		case '"':
+			s, err := strconv.Unquote(p.src[start : i+1])
+			if err != nil {
+				return nil, p.errorf("invalid string %s", p.src[start:i+1])
+			}
+			p.pos = i + 1
+			return &literalNode{val: s}, nil
+		}
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+func (p *parser) parseNumber() (node, error) {
+	start := p.pos
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+	s := p.src[start:p.pos]
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return &literalNode{val: v}, nil
+	}
+	if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return &literalNode{val: v}, nil
+	}
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return &literalNode{val: v}, nil
+	}
+	p.pos = start
+	return nil, p.errorf("invalid number %q", s)
+}
+
+func (p *parser) ident() string {
+	start := p.pos
+	for p.pos < len(p.src) && isIdent(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// accept 跳过空白后匹配s
+func (p *parser) accept(s string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// acceptKeyword 跳过空白后匹配完整的单词kw
+func (p *parser) acceptKeyword(kw string) bool {
+	p.skipSpace()
+	end := p.pos + len(kw)
+	if !strings.HasPrefix(p.src[p.pos:], kw) || (end < len(p.src) && isIdent(p.src[end])) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,90 @@
+// Package query 在没有.proto文件的情况下对codec.ProtoMessage执行类似jq的查询
+//
+// 语法（优先级从低到高）：
+//
+//	q | q          管道，右侧对左侧的每个结果分别求值
+//	q, q           投影，依次输出两侧的结果
+//	q or q, q and q, not q
+//	q == q, !=, <, <=, >, >=
+//	q as TYPE      按照字段类型解析，TYPE为int32、sint64、string、message等proto类型名
+//	.              当前输入
+//	17[].1         字段路径，格式与codec.ProtoMessage.GetPath一致，[]表示repeated字段的所有元素
+//	select(q)      q的结果中存在真值时输出当前输入
+//	1, -2.5, "s", true, false, (q)
+//
+// 字段路径可以省略开头的.，但比较运算符右侧的数字为字面量，路径需要以.开头。
+// 例如17[] | select(.1 as sint32 > 5) | .3 as string输出tag 17中tag 1大于5的元素的tag 3
+package query
+
+import (
+	"fmt"
+	"strconv"
+
+	codec "github.com/KarKLi/protobuf-golang-codec"
+)
+
+// Query 编译后的查询，可以在多个message上重复执行
+type Query struct {
+	src  string
+	root node
+}
+
+// SyntaxError 查询语句的语法错误
+type SyntaxError struct {
+	// Offset 出错位置在查询语句中的字节偏移量
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("query syntax error at offset %d: %s", e.Offset, e.Msg)
+}
+
+// Parse 编译查询语句，语法错误时返回*SyntaxError
+func Parse(src string) (*Query, error) {
+	p := &parser{src: src}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Query{src: src, root: root}, nil
+}
+
+// String 返回查询语句原文
+func (q *Query) String() string {
+	return q.src
+}
+
+// Run 在m上执行查询，返回所有结果
+//
+// 结果的类型为codec.ProtoMessage、codec.ProtoValue（未指定as的字段）、
+// as解析得到的类型（与codec.ProtoValue.DecodeKind一致）或比较运算得到的bool
+func (q *Query) Run(m codec.ProtoMessage) ([]interface{}, error) {
+	out, err := q.root.eval(m)
+	if err != nil {
+		return nil, fmt.Errorf("run query %q failed: %w", q.src, err)
+	}
+	return out, nil
+}
+
+// Format 将单个查询结果格式化为以换行结尾的文本
+//
+// codec.ProtoMessage和codec.ProtoValue按照protoc --decode_raw的格式输出，字符串和bytes按Go语法加引号输出
+func Format(v interface{}) string {
+	switch v := v.(type) {
+	case codec.ProtoMessage:
+		if len(v.Values) == 0 {
+			return "{}\n"
+		}
+		return codec.FormatRaw(v)
+	case codec.ProtoValue:
+		return codec.FormatRaw(codec.ProtoMessage{Values: []codec.ProtoValue{v}})
+	case string:
+		return strconv.Quote(v) + "\n"
+	case []byte:
+		return strconv.Quote(string(v)) + "\n"
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32) + "\n"
+	}
+	return fmt.Sprint(v) + "\n"
+}
@@ -0,0 +1,132 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	codec "github.com/KarKLi/protobuf-golang-codec"
+)
+
+func testMessage(t *testing.T) codec.ProtoMessage {
+	bin, err := codec.NewBuilder().
+		String(1, "root").
+		Message(17, codec.NewBuilder().Sint32(1, 3).String(3, "a")).
+		Message(17, codec.NewBuilder().Sint32(1, 7).String(3, "b")).
+		Message(17, codec.NewBuilder().Sint32(1, 9)).
+		Message(17, codec.NewBuilder().Sint32(1, -20).String(3, "d")).
+		Map(18, map[string]int32{"x": 1, "y": 2}, codec.StringKeyEncoder, codec.Int32ValueEncoder).
+		Double(11, 2.5).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	m, err := codec.Decode(bin, codec.NotSort)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	return m
+}
+
+func TestRun(t *testing.T) {
+	m := testMessage(t)
+	for _, c := range []struct {
+		query  string
+		expect []interface{}
+	}{
+		{`1 as string`, []interface{}{"root"}},
+		{`17[].1 as sint32 > 5`, []interface{}{false, true, true, false}},
+		{`17[] | select(.1 as sint32 > 5) | .3 as string`, []interface{}{"b"}},
+		{`17[] | select(.3) | .1 as sint32, .3 as string`, []interface{}{int32(3), "a", int32(7), "b", int32(-20), "d"}},
+		{`17[-1].1 as sint32`, []interface{}{int32(-20)}},
+		{`17[] | select(.1 as sint32 >= -20 and not (.3 as string == "d")) | .1 as sint32`, []interface{}{int32(3), int32(7)}},
+		{`17[] | select(.3 == "a" or .1 as sint32 < 0) | .3 as string`, []interface{}{"a", "d"}},
+		{`18{y} as int32`, []interface{}{int32(2)}},
+		{`11 as double > 2`, []interface{}{true}},
+		{`11 as double == 2.5, 11 as double != 2.5`, []interface{}{true, false}},
+		{`17[1].1 as sint32 == 7, 17[1].1 as sint32 == 18446744073709551615`, []interface{}{true, false}},
+		{`17[9].1`, []interface{}{}},
+		{`1 == true`, []interface{}{false}},
+	} {
+		q, err := Parse(c.query)
+		if err != nil {
+			t.Fatalf("parse query %q failed, err: %+v", c.query, err)
+		}
+		out, err := q.Run(m)
+		if err != nil {
+			t.Fatalf("run query %q failed, err: %+v", c.query, err)
+		}
+		if !reflect.DeepEqual(out, c.expect) {
+			t.Fatalf("query %q result %#v != expected %#v", c.query, out, c.expect)
+		}
+	}
+}
+
+func TestRunError(t *testing.T) {
+	m := testMessage(t)
+	for _, query := range []string{`1.2`, `17[0].1 as string`, `1 as string < true`, `. as int32`} {
+		q, err := Parse(query)
+		if err != nil {
+			t.Fatalf("parse query %q failed, err: %+v", query, err)
+		}
+		if _, err := q.Run(m); err == nil {
+			t.Fatalf("run query %q should fail", query)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	for query, offset := range map[string]int{
+		``:                 0,
+		`17[`:              2,
+		`17[].1 as sint33`: 10,
+		`select(.1`:        9,
+		`1 > `:             4,
+		`"abc`:             0,
+		`17 foo`:           3,
+		`.1 == -x`:         6,
+	} {
+		_, err := Parse(query)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("parse query %q should fail with syntax error, err: %+v", query, err)
+		}
+		if syntaxErr.Offset != offset {
+			t.Fatalf("query %q syntax error offset %d != expected %d", query, syntaxErr.Offset, offset)
+		}
+	}
+	// 错误信息包含不合法的路径
+	_, err := Parse(`17[x]`)
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Offset != 0 || !strings.Contains(syntaxErr.Msg, `"17[x]"`) {
+		t.Fatalf("parse invalid path should fail with syntax error, err: %+v", err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	m := testMessage(t)
+	q, err := Parse(`17[0], 17[0].3 as string, 17[0].1 as sint32, (17[1] as message | .3 as string)`)
+	if err != nil {
+		t.Fatalf("parse query failed, err: %+v", err)
+	}
+	out, err := q.Run(m)
+	if err != nil {
+		t.Fatalf("run query failed, err: %+v", err)
+	}
+	var got string
+	for _, v := range out {
+		got += Format(v)
+	}
+	expect := `17 {
+  1: 6
+  3: "a"
+}
+"a"
+3
+"b"
+`
+	if got != expect {
+		t.Fatalf("format result:\n%s\n!= expected:\n%s", got, expect)
+	}
+}
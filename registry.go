@@ -0,0 +1,259 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+var (
+	ErrMessageNotFound = errors.New("message not found in registry")
+	// ErrUnresolvedDependency message所在的文件依赖的文件尚未加载，暂时无法构建
+	ErrUnresolvedDependency = errors.New("imports of the file declaring the message are not loaded")
+)
+
+// Registry 从FileDescriptorSet（protoc --descriptor_set_out的产物）加载的描述集合
+//
+// 可以根据message全名（例如karkli.protobuf_codec.Msg）解析proto二进制流数据
+type Registry struct {
+	// protos 已加载的文件描述，按照加载顺序排列，同名文件只保留第一个
+	protos []*descriptorpb.FileDescriptorProto
+	files  *protoregistry.Files
+	// unresolved 依赖的文件尚未加载、暂时无法构建的文件
+	unresolved []*descriptorpb.FileDescriptorProto
+}
+
+// NewRegistry 创建一个空的Registry
+func NewRegistry() *Registry {
+	return &Registry{files: new(protoregistry.Files)}
+}
+
+// LoadDescriptorSetFile 从磁盘加载一个或多个序列化的FileDescriptorSet文件
+func (r *Registry) LoadDescriptorSetFile(paths ...string) error {
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := r.LoadDescriptorSet(b); err != nil {
+			return fmt.Errorf("load descriptor set %s failed: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// LoadDescriptorSet 加载序列化的FileDescriptorSet
+//
+// 依赖的文件可以在之前或之后加载的FileDescriptorSet中，也可以是已链接到程序中的文件（例如well-known types）；
+// 依赖尚未加载的文件暂不构建（见Unresolved），在之后加载的FileDescriptorSet补齐依赖时再构建；
+// 在此之前查找其中的message时FindMessage和Decode返回ErrUnresolvedDependency，错误信息包含缺少的依赖
+func (r *Registry) LoadDescriptorSet(b []byte) error {
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		return err
+	}
+	protos := r.protos
+	for _, fd := range set.GetFile() {
+		if r.hasProto(fd.GetName()) {
+			continue
+		}
+		protos = append(protos, fd)
+	}
+	files, unresolved, err := buildFiles(protos)
+	if err != nil {
+		return err
+	}
+	r.protos = protos
+	r.files = files
+	r.unresolved = unresolved
+	return nil
+}
+
+// Unresolved 返回因依赖的文件尚未加载而暂未构建的文件名，按照加载顺序排列
+func (r *Registry) Unresolved() []string {
+	names := make([]string, 0, len(r.unresolved))
+	for _, fdp := range r.unresolved {
+		names = append(names, fdp.GetName())
+	}
+	return names
+}
+
+// unresolvedError name在暂未构建的文件中声明时返回ErrUnresolvedDependency，包含文件名和缺少的依赖
+func (r *Registry) unresolvedError(name string) error {
+	for _, fdp := range r.unresolved {
+		if !declaresMessage(fdp, name) {
+			continue
+		}
+		var missing []string
+		for _, dep := range fdp.GetDependency() {
+			if _, err := r.files.FindFileByPath(dep); err == nil {
+				continue
+			}
+			if _, err := protoregistry.GlobalFiles.FindFileByPath(dep); err == nil {
+				continue
+			}
+			missing = append(missing, dep)
+		}
+		return fmt.Errorf("%w: %s is declared in %s, missing imports: %s",
+			ErrUnresolvedDependency, name, fdp.GetName(), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// declaresMessage 判断fdp中是否声明了全名为name的message（包括嵌套message）
+func declaresMessage(fdp *descriptorpb.FileDescriptorProto, name string) bool {
+	var walk func(prefix string, mds []*descriptorpb.DescriptorProto) bool
+	walk = func(prefix string, mds []*descriptorpb.DescriptorProto) bool {
+		for _, md := range mds {
+			fullName := prefix + md.GetName()
+			if fullName == name || walk(fullName+".", md.GetNestedType()) {
+				return true
+			}
+		}
+		return false
+	}
+	prefix := ""
+	if fdp.GetPackage() != "" {
+		prefix = fdp.GetPackage() + "."
+	}
+	return walk(prefix, fdp.GetMessageType())
+}
+
+func (r *Registry) hasProto(name string) bool {
+	for _, fd := range r.protos {
+		if fd.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// FindMessage 根据message全名查找描述，全名可以带有前缀"."
+func (r *Registry) FindMessage(name string) (protoreflect.MessageDescriptor, error) {
+	name = strings.TrimPrefix(name, ".")
+	d, err := r.files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		if errors.Is(err, protoregistry.NotFound) {
+			if err := r.unresolvedError(name); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, name)
+		}
+		return nil, err
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a message", ErrMessageNotFound, name)
+	}
+	return md, nil
+}
+
+// MessageNames 返回所有已加载message（包括嵌套message）的全名，按字典序排列
+func (r *Registry) MessageNames() []string {
+	var names []string
+	var walk func(mds protoreflect.MessageDescriptors)
+	walk = func(mds protoreflect.MessageDescriptors) {
+		for i := 0; i < mds.Len(); i++ {
+			md := mds.Get(i)
+			if md.IsMapEntry() {
+				continue
+			}
+			names = append(names, string(md.FullName()))
+			walk(md.Messages())
+		}
+	}
+	r.files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		walk(fd.Messages())
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+// Decode 根据message全名解析proto二进制流数据
+func (r *Registry) Decode(b []byte, name string) (*TypedMessage, error) {
+	md, err := r.FindMessage(name)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeWithDescriptor(b, md)
+}
+
+// buildFiles 按照依赖顺序构建文件描述，不要求protos本身按照依赖顺序排列
+//
+// 依赖无法解析的文件不构建，通过unresolved返回
+func buildFiles(protos []*descriptorpb.FileDescriptorProto) (files *protoregistry.Files, unresolved []*descriptorpb.FileDescriptorProto, err error) {
+	files = new(protoregistry.Files)
+	resolver := &chainResolver{files: files, names: make(map[string]bool, len(protos))}
+	for _, fdp := range protos {
+		resolver.names[fdp.GetName()] = true
+	}
+	pending := protos
+	for len(pending) > 0 {
+		var next []*descriptorpb.FileDescriptorProto
+		for _, fdp := range pending {
+			if !resolver.canResolve(fdp.GetDependency()) {
+				next = append(next, fdp)
+				continue
+			}
+			fd, err := protodesc.NewFile(fdp, resolver)
+			if err != nil {
+				return nil, nil, fmt.Errorf("build file %s failed: %w", fdp.GetName(), err)
+			}
+			if err := files.RegisterFile(fd); err != nil {
+				return nil, nil, err
+			}
+		}
+		if len(next) == len(pending) {
+			break
+		}
+		pending = next
+	}
+	return files, pending, nil
+}
+
+// chainResolver 优先从已加载的文件中查找，找不到时回退到protoregistry.GlobalFiles
+type chainResolver struct {
+	files *protoregistry.Files
+	// names 待加载的所有文件名，这些文件必须从files中解析
+	names map[string]bool
+}
+
+func (c *chainResolver) canResolve(deps []string) bool {
+	for _, dep := range deps {
+		var err error
+		if c.names[dep] {
+			_, err = c.files.FindFileByPath(dep)
+		} else {
+			_, err = protoregistry.GlobalFiles.FindFileByPath(dep)
+		}
+		if err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *chainResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	fd, err := c.files.FindFileByPath(path)
+	if err == nil {
+		return fd, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (c *chainResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	d, err := c.files.FindDescriptorByName(name)
+	if err == nil {
+		return d, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
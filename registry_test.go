@@ -0,0 +1,161 @@
+package codec
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/KarKLi/protobuf-golang-codec/internal/proto3_test"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func writeTestDescriptorSet(t *testing.T) string {
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(proto3_test.File_proto3_test_proto),
+		},
+	}
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatalf("can not marshal descriptor set, err: %+v", err)
+	}
+	path := filepath.Join(t.TempDir(), "proto3_test.pb")
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatalf("can not write descriptor set, err: %+v", err)
+	}
+	return path
+}
+
+func TestRegistryDecode(t *testing.T) {
+	path := writeTestDescriptorSet(t)
+	r := NewRegistry()
+	// 重复加载同一个文件不应报错
+	if err := r.LoadDescriptorSetFile(path, path); err != nil {
+		t.Fatalf("can not load descriptor set, err: %+v", err)
+	}
+	// proto3_test.pb.go生成时未带package，message全名即为message名
+	names := r.MessageNames()
+	expectNames := []string{
+		"Embeeded",
+		"Msg",
+		"RepeatedMsgWithPacked",
+		"RepeatedMsgWithUnpacked",
+	}
+	if !reflect.DeepEqual(names, expectNames) {
+		t.Fatalf("message names %v != expected %v", names, expectNames)
+	}
+	bin, err := proto.Marshal(testMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	m, err := r.Decode(bin, ".Msg")
+	if err != nil {
+		t.Fatalf("decode test proto message by name failed, err: %+v", err)
+	}
+	s12, ok := m.Get("s_12")
+	if !ok || s12 != testMsg.S_12 {
+		t.Fatalf("field s_12 parse result %v != real val %v", s12, testMsg.S_12)
+	}
+	m14, _ := m.Get("m_14")
+	checkTypedEmbeededEqual(t, m14, testMsg.M_14)
+
+	if _, err := r.FindMessage("NotExist"); !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("expect err %v, got %v", ErrMessageNotFound, err)
+	}
+	if _, err := r.FindMessage("TestEnum"); !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("expect err %v, got %v", ErrMessageNotFound, err)
+	}
+}
+
+func TestRegistryLoadDependencyLater(t *testing.T) {
+	dep := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("dep.proto"),
+		Package: proto.String("dep"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Inner"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("s"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				JsonName: proto.String("s"),
+			}},
+		}},
+	}
+	outer := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("main.proto"),
+		Package:    proto.String("main"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"dep.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Outer"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("inner"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".dep.Inner"),
+				JsonName: proto.String("inner"),
+			}},
+		}},
+	}
+	marshalSet := func(files ...*descriptorpb.FileDescriptorProto) []byte {
+		b, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: files})
+		if err != nil {
+			t.Fatalf("can not marshal descriptor set, err: %+v", err)
+		}
+		return b
+	}
+	r := NewRegistry()
+	// 依赖的文件尚未加载，暂不构建
+	if err := r.LoadDescriptorSet(marshalSet(outer)); err != nil {
+		t.Fatalf("can not load descriptor set, err: %+v", err)
+	}
+	if unresolved := r.Unresolved(); !reflect.DeepEqual(unresolved, []string{"main.proto"}) {
+		t.Fatalf("unresolved files %v != expected [main.proto]", unresolved)
+	}
+	// 查找暂未构建的文件中的message时返回缺少的依赖
+	_, err := r.FindMessage("main.Outer")
+	if !errors.Is(err, ErrUnresolvedDependency) || !strings.Contains(err.Error(), "main.proto") || !strings.Contains(err.Error(), "dep.proto") {
+		t.Fatalf("expect err %v with missing import dep.proto, got %v", ErrUnresolvedDependency, err)
+	}
+	if _, err := r.Decode(nil, "main.Outer"); !errors.Is(err, ErrUnresolvedDependency) {
+		t.Fatalf("expect err %v, got %v", ErrUnresolvedDependency, err)
+	}
+	if _, err := r.FindMessage("main.NotExist"); !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("expect err %v, got %v", ErrMessageNotFound, err)
+	}
+	// 加载依赖后一并构建
+	if err := r.LoadDescriptorSet(marshalSet(dep)); err != nil {
+		t.Fatalf("can not load descriptor set, err: %+v", err)
+	}
+	if unresolved := r.Unresolved(); len(unresolved) != 0 {
+		t.Fatalf("unexpected unresolved files %v", unresolved)
+	}
+	bin, err := NewBuilder().Message(1, NewBuilder().String(1, "hello")).Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	m, err := r.Decode(bin, "main.Outer")
+	if err != nil {
+		t.Fatalf("decode test proto message by name failed, err: %+v", err)
+	}
+	inner, ok := m.Get("inner")
+	if !ok {
+		t.Fatalf("field inner not found")
+	}
+	innerMsg, ok := inner.(*TypedMessage)
+	if !ok {
+		t.Fatalf("expect *TypedMessage, got %T", inner)
+	}
+	s, ok := innerMsg.Get("s")
+	if !ok || s != "hello" {
+		t.Fatalf("field inner.s parse result %v != real val hello", s)
+	}
+}
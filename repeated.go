@@ -0,0 +1,76 @@
+package codec
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// packedDecoders 可以使用[packed=true]编码的字段类型对应的packedRepeatedDecoder
+var packedDecoders = map[protoreflect.Kind]packedRepeatedDecoder{
+	protoreflect.BoolKind:     PackedRepeatedBoolDecoder,
+	protoreflect.EnumKind:     PackedRepeatedEnumDecoder,
+	protoreflect.Int32Kind:    PackedRepeatedInt32Decoder,
+	protoreflect.Sint32Kind:   PackedRepeatedSint32Decoder,
+	protoreflect.Uint32Kind:   PackedRepeatedUint32Decoder,
+	protoreflect.Int64Kind:    PackedRepeatedInt64Decoder,
+	protoreflect.Sint64Kind:   PackedRepeatedSint64Decoder,
+	protoreflect.Uint64Kind:   PackedRepeatedUint64Decoder,
+	protoreflect.Sfixed32Kind: PackedRepeatedSfixed32Decoder,
+	protoreflect.Fixed32Kind:  PackedRepeatedFixed32Decoder,
+	protoreflect.FloatKind:    PackedRepeatedFloatDecoder,
+	protoreflect.Sfixed64Kind: PackedRepeatedSfixed64Decoder,
+	protoreflect.Fixed64Kind:  PackedRepeatedFixed64Decoder,
+	protoreflect.DoubleKind:   PackedRepeatedDoubleDecoder,
+}
+
+// unpackedDecoders 不能使用[packed=true]编码的字段类型对应的unpackedRepeatedDecoder
+var unpackedDecoders = map[protoreflect.Kind]unpackedRepeatedDecoder{
+	protoreflect.StringKind:  UnpackedRepeatedStringDecoder,
+	protoreflect.BytesKind:   UnpackedRepeatedBytesDecoder,
+	protoreflect.MessageKind: UnpackedRepeatedMessageDecoder,
+	protoreflect.GroupKind:   unpackedRepeatedGroupDecoder,
+}
+
+// unpackedRepeatedGroupDecoder 解码repeated group，返回[]ProtoMessage
+var unpackedRepeatedGroupDecoder unpackedRepeatedDecoder = func(m ProtoMessage, idxs []int) (interface{}, error) {
+	result := make([]ProtoMessage, 0, len(idxs))
+	for i := range idxs {
+		group, err := m.Values[idxs[i]].parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, group)
+	}
+	return result, nil
+}
+
+// DecodeRepeated 按照字段类型解析repeated字段，根据每次出现的wire type自动区分packed和unpacked编码
+//
+// 不同的实现默认编码不同（proto2默认unpacked，proto3默认packed，editions通过repeated_field_encoding指定），
+// 数字类型同时接受多段packed数据和未packed的元素，返回值的类型与对应的PackedRepeatedXXXDecoder一致；
+// string、bytes和message与对应的UnpackedRepeatedXXXDecoder一致，group返回[]ProtoMessage。
+// 任意一次出现的wire type与字段类型不匹配时返回ErrTypeMismatch
+func (p ProtoMessage) DecodeRepeated(tag protowire.Number, kind protoreflect.Kind) (interface{}, error) {
+	packed, isPacked := packedDecoders[kind]
+	unpacked, isUnpacked := unpackedDecoders[kind]
+	if !isPacked && !isUnpacked {
+		return nil, fmt.Errorf("not support proto kind %v", kind)
+	}
+	idxs, err := p.GetRepeatedData(tag)
+	if err != nil {
+		return nil, err
+	}
+	wireType := kindWireType(kind)
+	for _, i := range idxs {
+		typ := p.Values[i]._type
+		if typ != wireType && !(isPacked && typ == protowire.BytesType) {
+			return nil, ErrTypeMismatch
+		}
+	}
+	if isPacked {
+		return p.decodePacked(tag, idxs, packed)
+	}
+	return unpacked(p, idxs)
+}
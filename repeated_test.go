@@ -0,0 +1,103 @@
+package codec
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/KarKLi/protobuf-golang-codec/internal/proto3_test"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestDecodeRepeated(t *testing.T) {
+	packed, err := proto.Marshal(testPackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	unpacked, err := proto.Marshal(testUnpackedRepeatedMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	// 同一个字段的packed和unpacked数据混合出现
+	bin := append(append(append([]byte{}, packed...), unpacked...), packed...)
+	expect := &proto3_test.RepeatedMsgWithUnpacked{}
+	if err := proto.Unmarshal(bin, expect); err != nil {
+		t.Fatalf("can not unmarshal test proto message, err: %+v", err)
+	}
+	for _, sortType := range []MessageSortType{NotSort, Asc, Desc} {
+		m, err := Decode(bin, sortType)
+		if err != nil {
+			t.Fatalf("decode test proto message failed, err: %+v", err)
+		}
+		fields := expect.ProtoReflect().Descriptor().Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if fd.IsMap() || fd.Kind() == protoreflect.MessageKind {
+				continue
+			}
+			got, err := m.DecodeRepeated(fd.Number(), fd.Kind())
+			if err != nil {
+				t.Fatalf("can not decode tag %d as %v, err: %+v", fd.Number(), fd.Kind(), err)
+			}
+			list := expect.ProtoReflect().Get(fd).List()
+			rv := reflect.ValueOf(got)
+			if rv.Len() != list.Len() {
+				t.Fatalf("tag %d parse %d elements != real %d elements", fd.Number(), rv.Len(), list.Len())
+			}
+			for j := 0; j < list.Len(); j++ {
+				if g, w := fmt.Sprint(rv.Index(j).Interface()), fmt.Sprint(list.Get(j).Interface()); g != w {
+					t.Fatalf("tag %d element %d parse result %s != real val %s", fd.Number(), j, g, w)
+				}
+			}
+		}
+		msgs, err := m.DecodeRepeated(17, protoreflect.MessageKind)
+		if err != nil || len(msgs.([]ProtoMessage)) != len(expect.M_17) {
+			t.Fatalf("can not decode tag 17 as message, got %+v, err: %+v", msgs, err)
+		}
+	}
+
+	m, _ := Decode(bin, NotSort)
+	if _, err := m.DecodeRepeated(1, protoreflect.FloatKind); err != ErrTypeMismatch {
+		t.Fatalf("decode varint as float should fail, err: %+v", err)
+	}
+	if _, err := m.DecodeRepeated(1, protoreflect.StringKind); err != ErrTypeMismatch {
+		t.Fatalf("decode varint as string should fail, err: %+v", err)
+	}
+	if _, err := m.DecodeRepeated(1, protoreflect.Kind(0)); err == nil {
+		t.Fatalf("decode invalid kind should fail")
+	}
+	if got, err := m.DecodeRepeated(99, protoreflect.Int32Kind); err != nil || len(got.([]int32)) != 0 {
+		t.Fatalf("decode missing tag should return empty result, got %+v, err: %+v", got, err)
+	}
+}
+
+func TestDecodeRepeatedGroup(t *testing.T) {
+	bin, err := NewBuilder().
+		Group(1, NewBuilder().Int32(2, 1)).
+		Int32(3, 0).
+		Group(1, NewBuilder().Int32(2, 2)).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	m, err := Decode(bin, NotSort)
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	got, err := m.DecodeRepeated(1, protoreflect.GroupKind)
+	if err != nil {
+		t.Fatalf("can not decode tag 1 as group, err: %+v", err)
+	}
+	groups := got.([]ProtoMessage)
+	if len(groups) != 2 {
+		t.Fatalf("parse %d groups != real 2 groups", len(groups))
+	}
+	for i, group := range groups {
+		v, _ := group.GetData(protowire.Number(2))
+		if realI, err := v.DecodeInt32(); err != nil || realI != int32(i+1) {
+			t.Fatalf("group %d parse result %d != real val %d, err: %+v", i, realI, i+1, err)
+		}
+	}
+}
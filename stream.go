@@ -0,0 +1,367 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+	ErrPayloadConsumed = errors.New("payload of current field has been consumed")
+)
+
+// StreamDecoder 从io.Reader中逐个读取字段，内存占用与单个字段的大小相关，与整个message的大小无关
+//
+// 典型用法：
+//
+//	d := NewStreamDecoder(r)
+//	for d.Next() {
+//		switch d.Tag() {
+//		case 1:
+//			v, err := d.Value()
+//		case 2:
+//			sub, err := d.Descend()
+//		}
+//	}
+//	if err := d.Err(); err != nil {
+//	}
+//
+// 未读取的数据在下一次调用Next时跳过
+type StreamDecoder struct {
+	s *streamReader
+	// end 当前message在输入数据中的结束位置，-1表示直到io.EOF
+	end int
+	// group 当前message为group时的tag，读取到对应的结束tag时结束
+	group protowire.Number
+	// path 当前message的tag路径
+	path []protowire.Number
+	// child Descend返回的嵌套message
+	child *StreamDecoder
+	// endTagLen group结束tag占用的字节数
+	endTagLen int
+
+	tag  protowire.Number
+	typ  protowire.Type
+	span FieldSpan
+	// val VarintType/Fixed32Type/Fixed64Type的数据
+	val interface{}
+	// pending 当前字段的数据尚未读取
+	pending bool
+	done    bool
+	err     error
+}
+
+// NewStreamDecoder 创建从r中读取proto二进制流数据的StreamDecoder
+func NewStreamDecoder(r io.Reader) *StreamDecoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &StreamDecoder{s: &streamReader{r: br}, end: -1}
+}
+
+// Next 读取下一个字段的tag和wire type，并读取varint/fixed32/fixed64的数据
+//
+// 当前message结束或出错时返回false，通过Err获取错误
+func (d *StreamDecoder) Next() bool {
+	if d.done || d.err != nil {
+		return false
+	}
+	if err := d.finish(); err != nil {
+		d.err = err
+		return false
+	}
+	if d.end >= 0 && d.s.pos >= d.end {
+		if d.group != 0 {
+			// group缺少结束tag
+			d.err = d.newError(d.s.pos, 0, 0, io.ErrUnexpectedEOF)
+			return false
+		}
+		d.done = true
+		return false
+	}
+	offset := d.s.pos
+	tag, err := d.s.readVarint()
+	if err == io.EOF && d.end < 0 && d.group == 0 {
+		d.done = true
+		return false
+	}
+	if err != nil {
+		d.err = d.newError(offset, 0, 0, err)
+		return false
+	}
+	num, typ, n := protowire.ConsumeTag(tag)
+	if n < 0 {
+		d.err = d.newError(offset, 0, 0, protowire.ParseError(n))
+		return false
+	}
+	if typ == protowire.EndGroupType {
+		if num != d.group {
+			d.err = d.newError(offset, num, typ, ErrUnexpectedEndGroup)
+			return false
+		}
+		d.endTagLen = n
+		d.done = true
+		return false
+	}
+	d.tag, d.typ, d.val, d.pending = num, typ, nil, false
+	d.span = FieldSpan{Offset: offset, TagLen: n}
+	switch typ {
+	case protowire.VarintType:
+		var b []byte
+		if b, err = d.s.readVarint(); err == nil {
+			var val uint64
+			val, n = protowire.ConsumeVarint(b)
+			if n < 0 {
+				err = protowire.ParseError(n)
+			}
+			d.val, d.span.PayloadLen = val, n
+		}
+	case protowire.Fixed32Type:
+		var b [4]byte
+		if err = d.s.readFull(b[:]); err == nil {
+			d.val, _ = protowire.ConsumeFixed32(b[:])
+			d.span.PayloadLen = len(b)
+		}
+	case protowire.Fixed64Type:
+		var b [8]byte
+		if err = d.s.readFull(b[:]); err == nil {
+			d.val, _ = protowire.ConsumeFixed64(b[:])
+			d.span.PayloadLen = len(b)
+		}
+	case protowire.BytesType:
+		var b []byte
+		if b, err = d.s.readVarint(); err == nil {
+			var size uint64
+			size, n = protowire.ConsumeVarint(b)
+			switch {
+			case n < 0:
+				err = protowire.ParseError(n)
+			case d.end >= 0 && size > uint64(d.end-d.s.pos):
+				err = io.ErrUnexpectedEOF
+			case size > uint64(math.MaxInt-d.s.pos):
+				// 顶层没有长度限制，但数据结束位置同样不能超出int的范围
+				err = io.ErrUnexpectedEOF
+			}
+			d.span.PrefixLen, d.span.PayloadLen = n, int(size)
+		}
+		d.pending = true
+	case protowire.StartGroupType:
+		d.pending = true
+	default:
+		err = fmt.Errorf("not support proto data type %d", typ)
+	}
+	if err == nil && d.end >= 0 && d.s.pos > d.end {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		d.err = d.newError(offset, num, typ, err)
+		return false
+	}
+	return true
+}
+
+// Err 返回读取过程中遇到的错误，正常结束时返回nil
+func (d *StreamDecoder) Err() error {
+	return d.err
+}
+
+// Tag 返回当前字段的tag
+func (d *StreamDecoder) Tag() protowire.Number {
+	return d.tag
+}
+
+// Type 返回当前字段的wire type
+func (d *StreamDecoder) Type() protowire.Type {
+	return d.typ
+}
+
+// Span 返回当前字段在输入数据中占用的字节范围，group的PayloadLen和EndTagLen在数据读取完成前为0
+func (d *StreamDecoder) Span() FieldSpan {
+	return d.span
+}
+
+// Value 读取当前字段的完整数据
+//
+// BytesType的数据全部读入内存，group解析为嵌套ProtoMessage；数据已经被Skip或Descend读取时返回ErrPayloadConsumed
+func (d *StreamDecoder) Value() (ProtoValue, error) {
+	if d.err != nil {
+		return ProtoValue{}, d.err
+	}
+	v := ProtoValue{_type: d.typ, val: d.val, tag: d.tag}
+	switch d.typ {
+	case protowire.BytesType:
+		if !d.pending {
+			return ProtoValue{}, ErrPayloadConsumed
+		}
+		d.pending = false
+		payload, err := d.s.readBytes(d.span.PayloadLen)
+		if err != nil {
+			d.err = d.newError(d.span.Offset, d.tag, d.typ, err)
+			return ProtoValue{}, d.err
+		}
+		v.val = payload
+	case protowire.StartGroupType:
+		child, err := d.Descend()
+		if err != nil {
+			return ProtoValue{}, err
+		}
+		group, err := child.message()
+		if err != nil {
+			d.err = err
+			return ProtoValue{}, err
+		}
+		d.span.PayloadLen = d.s.pos - child.endTagLen - d.span.PayloadOffset()
+		d.span.EndTagLen = child.endTagLen
+		v.val = group
+	}
+	v.ext = &valueExt{spanned: true, span: d.span, path: d.path}
+	return v, nil
+}
+
+// Skip 跳过当前字段未读取的数据
+func (d *StreamDecoder) Skip() error {
+	if d.err != nil {
+		return d.err
+	}
+	if err := d.finish(); err != nil {
+		d.err = err
+	}
+	return d.err
+}
+
+// Descend 返回读取当前BytesType或group字段内嵌套message的StreamDecoder
+//
+// 返回的StreamDecoder与d共享底层数据，调用d.Next时跳过嵌套message中未读取的部分
+func (d *StreamDecoder) Descend() (*StreamDecoder, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if d.typ != protowire.BytesType && d.typ != protowire.StartGroupType {
+		return nil, ErrTypeMismatch
+	}
+	if !d.pending {
+		return nil, ErrPayloadConsumed
+	}
+	d.pending = false
+	child := &StreamDecoder{s: d.s, end: d.end, path: appendPath(d.path, d.tag)}
+	if d.typ == protowire.BytesType {
+		child.end = d.span.PayloadOffset() + d.span.PayloadLen
+	} else {
+		child.group = d.tag
+	}
+	d.child = child
+	return child, nil
+}
+
+// finish 跳过当前字段未读取的数据，包括Descend返回的嵌套message中未读取的部分
+func (d *StreamDecoder) finish() error {
+	if d.pending {
+		if _, err := d.Descend(); err != nil {
+			return err
+		}
+	}
+	if d.child == nil {
+		return nil
+	}
+	child := d.child
+	d.child = nil
+	if child.group == 0 && child.err == nil {
+		// 长度已知，直接跳过
+		if err := d.s.discard(child.end - d.s.pos); err != nil {
+			return d.newError(d.span.Offset, d.tag, d.typ, err)
+		}
+		for c := child; c != nil; c = c.child {
+			c.done = true
+		}
+		return nil
+	}
+	for child.Next() {
+	}
+	return child.err
+}
+
+// message 读取当前message的所有字段
+func (d *StreamDecoder) message() (ProtoMessage, error) {
+	m := ProtoMessage{Values: make([]ProtoValue, 0, 16)}
+	for d.Next() {
+		v, err := d.Value()
+		if err != nil {
+			return ProtoMessage{}, err
+		}
+		m.Values = append(m.Values, v)
+	}
+	if d.err != nil {
+		return ProtoMessage{}, d.err
+	}
+	return m, nil
+}
+
+func (d *StreamDecoder) newError(offset int, tag protowire.Number, typ protowire.Type, err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return &DecodeError{Offset: offset, Tag: tag, WireType: typ, Path: d.path, Err: err}
+}
+
+// streamReader 记录已读取字节数的bufio.Reader，由同一数据的所有StreamDecoder共享
+type streamReader struct {
+	r   *bufio.Reader
+	pos int
+}
+
+// readVarint 读取一个varint的原始数据，数据为空时返回io.EOF
+func (s *streamReader) readVarint() ([]byte, error) {
+	var buf [binary.MaxVarintLen64]byte
+	for i := 0; i < len(buf); i++ {
+		c, err := s.r.ReadByte()
+		if err != nil {
+			if i > 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		s.pos++
+		buf[i] = c
+		if c < 0x80 {
+			return buf[:i+1], nil
+		}
+	}
+	// 交给protowire.ConsumeVarint返回溢出错误
+	return buf[:], nil
+}
+
+func (s *streamReader) readFull(b []byte) error {
+	n, err := io.ReadFull(s.r, b)
+	s.pos += n
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readBytes 读取n字节数据，按实际读取到的数据分配内存，避免错误的长度导致过大的内存分配
+func (s *streamReader) readBytes(n int) ([]byte, error) {
+	var buf bytes.Buffer
+	m, err := io.CopyN(&buf, s.r, int64(n))
+	s.pos += int(m)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), err
+}
+
+func (s *streamReader) discard(n int) error {
+	m, err := s.r.Discard(n)
+	s.pos += m
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,193 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"testing/iotest"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestStreamDecoder(t *testing.T) {
+	bin, err := proto.Marshal(testMsg)
+	if err != nil {
+		t.Fatalf("can not marshal test proto message, err: %+v", err)
+	}
+	m, err := DecodeWithOptions(bin, DecodeOptions{Spans: true})
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	// 逐字节读取，覆盖数据跨越缓冲区的情况
+	d := NewStreamDecoder(iotest.OneByteReader(bytes.NewReader(bin)))
+	var i int
+	for d.Next() {
+		v, err := d.Value()
+		if err != nil {
+			t.Fatalf("read stream value failed, err: %+v", err)
+		}
+		expect := m.Values[i]
+		if v.tag != expect.tag || v._type != expect._type || v.Span() != expect.Span() || !reflect.DeepEqual(v.val, expect.val) {
+			t.Fatalf("stream value %+v != decoded value %+v", v, expect)
+		}
+		i++
+	}
+	if err := d.Err(); err != nil {
+		t.Fatalf("read stream failed, err: %+v", err)
+	}
+	if i != len(m.Values) {
+		t.Fatalf("stream value count %d != decoded value count %d", i, len(m.Values))
+	}
+}
+
+func TestStreamDecoderDescend(t *testing.T) {
+	bin, err := NewBuilder().
+		Uint64(1, 150).
+		Message(2, NewBuilder().String(1, "skipped").Message(2, NewBuilder().Int32(1, 7)).Fixed32(3, 1)).
+		Group(3, NewBuilder().Fixed64(1, 2).String(2, "in group")).
+		Bytes(4, make([]byte, 1000)).
+		Sint32(5, -1).
+		Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	d := NewStreamDecoder(bytes.NewReader(bin))
+	var tags []protowire.Number
+	for d.Next() {
+		tags = append(tags, d.Tag())
+		switch d.Tag() {
+		case 2:
+			sub, err := d.Descend()
+			if err != nil {
+				t.Fatalf("descend tag 2 failed, err: %+v", err)
+			}
+			// 只读取tag 2_tag 2_tag 1，其余数据由外层跳过
+			for sub.Next() {
+				if sub.Tag() != 2 {
+					continue
+				}
+				inner, err := sub.Descend()
+				if err != nil {
+					t.Fatalf("descend tag 2_tag 2 failed, err: %+v", err)
+				}
+				if !inner.Next() {
+					t.Fatalf("can not read tag 2_tag 2_tag 1, err: %+v", inner.Err())
+				}
+				v, _ := inner.Value()
+				if val, err := v.DecodeInt32(); err != nil || val != 7 {
+					t.Fatalf("parse result %d != real val %d, err: %+v", val, 7, err)
+				}
+				break
+			}
+			if _, err := d.Value(); err != ErrPayloadConsumed {
+				t.Fatalf("expect err %v, got %v", ErrPayloadConsumed, err)
+			}
+		case 3:
+			sub, err := d.Descend()
+			if err != nil {
+				t.Fatalf("descend tag 3 failed, err: %+v", err)
+			}
+			if !sub.Next() || sub.Tag() != 1 {
+				t.Fatalf("can not read tag 3_tag 1, err: %+v", sub.Err())
+			}
+		case 4:
+			if err := d.Skip(); err != nil {
+				t.Fatalf("skip tag 4 failed, err: %+v", err)
+			}
+		case 5:
+			v, err := d.Value()
+			if err != nil {
+				t.Fatalf("read tag 5 failed, err: %+v", err)
+			}
+			if val, err := v.DecodeSint32(); err != nil || val != -1 {
+				t.Fatalf("parse result %d != real val %d, err: %+v", val, -1, err)
+			}
+		}
+	}
+	if err := d.Err(); err != nil {
+		t.Fatalf("read stream failed, err: %+v", err)
+	}
+	if !reflect.DeepEqual(tags, []protowire.Number{1, 2, 3, 4, 5}) {
+		t.Fatalf("stream tags %v != %v", tags, []protowire.Number{1, 2, 3, 4, 5})
+	}
+}
+
+func TestStreamDecoderGroupValue(t *testing.T) {
+	bin, err := NewBuilder().Group(3, NewBuilder().Fixed64(1, 2).Group(4, NewBuilder().String(5, "inner"))).Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	m, err := DecodeWithOptions(bin, DecodeOptions{Spans: true})
+	if err != nil {
+		t.Fatalf("decode test proto message failed, err: %+v", err)
+	}
+	d := NewStreamDecoder(bytes.NewReader(bin))
+	if !d.Next() {
+		t.Fatalf("can not read tag 3, err: %+v", d.Err())
+	}
+	v, err := d.Value()
+	if err != nil {
+		t.Fatalf("read tag 3 failed, err: %+v", err)
+	}
+	if v.Span() != m.Values[0].Span() {
+		t.Fatalf("stream span %+v != decoded span %+v", v.Span(), m.Values[0].Span())
+	}
+	result, err := Encode(ProtoMessage{Values: []ProtoValue{v}})
+	if err != nil {
+		t.Fatalf("encode stream value failed, err: %+v", err)
+	}
+	if !bytes.Equal(result, bin) {
+		t.Fatalf("encode result %v != origin data %v", result, bin)
+	}
+	if d.Next() || d.Err() != nil {
+		t.Fatalf("expect end of stream, err: %+v", d.Err())
+	}
+}
+
+func TestStreamDecoderError(t *testing.T) {
+	bin, err := NewBuilder().Uint64(1, 150).Message(2, NewBuilder().String(1, "abc")).Marshal()
+	if err != nil {
+		t.Fatalf("can not build test proto message, err: %+v", err)
+	}
+	// 截断tag 2_tag 1的数据
+	d := NewStreamDecoder(bytes.NewReader(bin[:len(bin)-1]))
+	for d.Next() {
+		if d.Tag() != 2 {
+			continue
+		}
+		sub, err := d.Descend()
+		if err != nil {
+			t.Fatalf("descend tag 2 failed, err: %+v", err)
+		}
+		for sub.Next() {
+			if _, err := sub.Value(); err == nil {
+				t.Fatalf("expect error for truncated data")
+			}
+		}
+	}
+	var decodeErr *DecodeError
+	if !errors.As(d.Err(), &decodeErr) {
+		t.Fatalf("expect *DecodeError, got %v", d.Err())
+	}
+	if decodeErr.Offset != 5 || decodeErr.FieldPath() != "2.1" || !errors.Is(decodeErr, io.ErrUnexpectedEOF) {
+		t.Fatalf("unexpected decode error %+v", decodeErr)
+	}
+}
+
+func TestStreamDecoderHugeLength(t *testing.T) {
+	// 顶层字段的长度超出int范围
+	bin := protowire.AppendTag(nil, 1, protowire.BytesType)
+	bin = protowire.AppendVarint(bin, math.MaxUint64)
+	bin = append(bin, 1, 2, 3)
+	d := NewStreamDecoder(bytes.NewReader(bin))
+	if d.Next() {
+		t.Fatalf("expect error for huge length, span %+v", d.Span())
+	}
+	if !errors.Is(d.Err(), io.ErrUnexpectedEOF) {
+		t.Fatalf("expect err %v, got %v", io.ErrUnexpectedEOF, d.Err())
+	}
+}
@@ -0,0 +1,140 @@
+package codec
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 与unpackedRepeatedDecoder对应，每个元素都编码为一个独立的字段
+
+// UnpackedInt32 写入[packed=false]的repeated int32
+func (b *MessageBuilder) UnpackedInt32(tag protowire.Number, vals []int32) *MessageBuilder {
+	for _, v := range vals {
+		b.Int32(tag, v)
+	}
+	return b
+}
+
+// UnpackedInt64 写入[packed=false]的repeated int64
+func (b *MessageBuilder) UnpackedInt64(tag protowire.Number, vals []int64) *MessageBuilder {
+	for _, v := range vals {
+		b.Int64(tag, v)
+	}
+	return b
+}
+
+// UnpackedUint32 写入[packed=false]的repeated uint32
+func (b *MessageBuilder) UnpackedUint32(tag protowire.Number, vals []uint32) *MessageBuilder {
+	for _, v := range vals {
+		b.Uint32(tag, v)
+	}
+	return b
+}
+
+// UnpackedUint64 写入[packed=false]的repeated uint64
+func (b *MessageBuilder) UnpackedUint64(tag protowire.Number, vals []uint64) *MessageBuilder {
+	for _, v := range vals {
+		b.Uint64(tag, v)
+	}
+	return b
+}
+
+// UnpackedSint32 写入[packed=false]的repeated sint32
+func (b *MessageBuilder) UnpackedSint32(tag protowire.Number, vals []int32) *MessageBuilder {
+	for _, v := range vals {
+		b.Sint32(tag, v)
+	}
+	return b
+}
+
+// UnpackedSint64 写入[packed=false]的repeated sint64
+func (b *MessageBuilder) UnpackedSint64(tag protowire.Number, vals []int64) *MessageBuilder {
+	for _, v := range vals {
+		b.Sint64(tag, v)
+	}
+	return b
+}
+
+// UnpackedBool 写入[packed=false]的repeated bool
+func (b *MessageBuilder) UnpackedBool(tag protowire.Number, vals []bool) *MessageBuilder {
+	for _, v := range vals {
+		b.Bool(tag, v)
+	}
+	return b
+}
+
+// UnpackedEnum 写入[packed=false]的repeated enum（本质是[]int32）
+func (b *MessageBuilder) UnpackedEnum(tag protowire.Number, vals []int32) *MessageBuilder {
+	return b.UnpackedInt32(tag, vals)
+}
+
+// UnpackedFixed64 写入[packed=false]的repeated fixed64
+func (b *MessageBuilder) UnpackedFixed64(tag protowire.Number, vals []uint64) *MessageBuilder {
+	for _, v := range vals {
+		b.Fixed64(tag, v)
+	}
+	return b
+}
+
+// UnpackedSfixed64 写入[packed=false]的repeated sfixed64
+func (b *MessageBuilder) UnpackedSfixed64(tag protowire.Number, vals []int64) *MessageBuilder {
+	for _, v := range vals {
+		b.Sfixed64(tag, v)
+	}
+	return b
+}
+
+// UnpackedDouble 写入[packed=false]的repeated double
+func (b *MessageBuilder) UnpackedDouble(tag protowire.Number, vals []float64) *MessageBuilder {
+	for _, v := range vals {
+		b.Double(tag, v)
+	}
+	return b
+}
+
+// UnpackedString 写入repeated string
+func (b *MessageBuilder) UnpackedString(tag protowire.Number, vals []string) *MessageBuilder {
+	for _, v := range vals {
+		b.String(tag, v)
+	}
+	return b
+}
+
+// UnpackedBytes 写入repeated bytes
+func (b *MessageBuilder) UnpackedBytes(tag protowire.Number, vals [][]byte) *MessageBuilder {
+	for _, v := range vals {
+		b.Bytes(tag, v)
+	}
+	return b
+}
+
+// UnpackedMessage 写入repeated message
+func (b *MessageBuilder) UnpackedMessage(tag protowire.Number, subs []*MessageBuilder) *MessageBuilder {
+	for _, sub := range subs {
+		b.Message(tag, sub)
+	}
+	return b
+}
+
+// UnpackedFixed32 写入[packed=false]的repeated fixed32
+func (b *MessageBuilder) UnpackedFixed32(tag protowire.Number, vals []uint32) *MessageBuilder {
+	for _, v := range vals {
+		b.Fixed32(tag, v)
+	}
+	return b
+}
+
+// UnpackedSfixed32 写入[packed=false]的repeated sfixed32
+func (b *MessageBuilder) UnpackedSfixed32(tag protowire.Number, vals []int32) *MessageBuilder {
+	for _, v := range vals {
+		b.Sfixed32(tag, v)
+	}
+	return b
+}
+
+// UnpackedFloat 写入[packed=false]的repeated float
+func (b *MessageBuilder) UnpackedFloat(tag protowire.Number, vals []float32) *MessageBuilder {
+	for _, v := range vals {
+		b.Float(tag, v)
+	}
+	return b
+}